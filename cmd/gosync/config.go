@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// passwordEnv is the environment variable the password is read from when neither the config file nor the flags
+// set it, so it does not have to appear on the command line.
+const passwordEnv = "GOSYNC_PASSWORD"
+
+// config is the configuration of a gosync run: the YAML file of --config, overridden by the flags given.
+type config struct {
+	//Protocol is ftp or sftp, the first argument of the command line
+	Protocol string `yaml:"protocol"`
+	//Host is the host of the server
+	Host string `yaml:"host"`
+	//Port is the port of the server, 21 for ftp and 22 for sftp if zero
+	Port int `yaml:"port"`
+	//Username is the user to log in as
+	Username string `yaml:"username"`
+	//Password is the password to log in with. For sftp, the key in ~/.ssh/id_rsa is used when it is empty
+	Password string `yaml:"password"`
+	//Local is the local directory to sync
+	Local string `yaml:"local"`
+	//Remote is the remote directory to sync, "/" if empty
+	Remote string `yaml:"remote"`
+	//Direction is up to sync Local to Remote, or down to sync Remote to Local
+	Direction string `yaml:"direction"`
+	//Workers is the number of files transferred at once, the default of the package if zero
+	Workers int `yaml:"workers"`
+	//MaxRetries is the number of attempts of a transfer, the default of the package if zero
+	MaxRetries int `yaml:"maxRetries"`
+	//Watch syncs the directories and then keeps them in sync until the process is interrupted
+	Watch bool `yaml:"watch"`
+	//Once syncs the directories once and exits
+	Once bool `yaml:"once"`
+	//DryRun prints the files a sync would transfer and exits
+	DryRun bool `yaml:"dryRun"`
+}
+
+// usage is printed by -h and with the errors of the command line.
+const usage = `Usage: gosync ftp|sftp [flags]
+
+Syncs a local directory with a directory on an FTP or SFTP server, in either direction.
+
+  gosync ftp --local /path --remote /remote --host ftp.example.com --direction up --watch
+  gosync sftp --config config.yaml
+
+The YAML file of --config holds the options of the flags below, e.g. "host: example.com" or "dryRun: true".
+The flags given override it. The password can also be set in the ` + passwordEnv + ` environment variable.
+
+Flags:
+`
+
+// parseArgs returns the config of the command line args, the arguments following the program name.
+func parseArgs(args []string, output io.Writer) (*config, error) {
+	cfg := &config{}
+	var path string
+	flags := flag.NewFlagSet("gosync", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(output, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&path, "config", "", "the YAML `file` to read the options from")
+	flags.StringVar(&cfg.Host, "host", "", "the host of the server")
+	flags.IntVar(&cfg.Port, "port", 0, "the port of the server, 21 for ftp and 22 for sftp by default")
+	flags.StringVar(&cfg.Username, "user", "", "the user to log in as")
+	flags.StringVar(&cfg.Password, "password", "", "the password to log in with; for sftp, the key in ~/.ssh/id_rsa is used without one")
+	flags.StringVar(&cfg.Local, "local", "", "the local directory to sync")
+	flags.StringVar(&cfg.Remote, "remote", "", "the remote directory to sync, / by default")
+	flags.StringVar(&cfg.Direction, "direction", "", "up to sync the local directory to the remote one, down for the reverse")
+	flags.IntVar(&cfg.Workers, "workers", 0, "the number of files transferred at once")
+	flags.IntVar(&cfg.MaxRetries, "max-retries", 0, "the number of attempts of a transfer")
+	flags.BoolVar(&cfg.Watch, "watch", false, "sync, then keep the directories in sync until interrupted")
+	flags.BoolVar(&cfg.Once, "once", false, "sync once and exit")
+	flags.BoolVar(&cfg.DryRun, "dry-run", false, "print the files a sync would transfer and exit")
+	if len(args) == 0 || args[0] != "ftp" && args[0] != "sftp" {
+		flags.Usage()
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			return nil, flag.ErrHelp
+		}
+		return nil, errors.New("the first argument must be ftp or sftp")
+	}
+	cfg.Protocol = args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	if path != "" {
+		fileCfg, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		if fileCfg.Protocol != "" && fileCfg.Protocol != cfg.Protocol {
+			return nil, fmt.Errorf("%s is for %s, not %s", path, fileCfg.Protocol, cfg.Protocol)
+		}
+		fileCfg.Protocol = cfg.Protocol
+		// The flags given override the file.
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "host":
+				fileCfg.Host = cfg.Host
+			case "port":
+				fileCfg.Port = cfg.Port
+			case "user":
+				fileCfg.Username = cfg.Username
+			case "password":
+				fileCfg.Password = cfg.Password
+			case "local":
+				fileCfg.Local = cfg.Local
+			case "remote":
+				fileCfg.Remote = cfg.Remote
+			case "direction":
+				fileCfg.Direction = cfg.Direction
+			case "workers":
+				fileCfg.Workers = cfg.Workers
+			case "max-retries":
+				fileCfg.MaxRetries = cfg.MaxRetries
+			case "watch":
+				fileCfg.Watch = cfg.Watch
+			case "once":
+				fileCfg.Once = cfg.Once
+			case "dry-run":
+				fileCfg.DryRun = cfg.DryRun
+			}
+		})
+		cfg = fileCfg
+	}
+	if cfg.Password == "" {
+		cfg.Password = os.Getenv(passwordEnv)
+	}
+	if cfg.Remote == "" {
+		cfg.Remote = "/"
+	}
+	return cfg, cfg.validate()
+}
+
+// loadConfig reads the config stored as YAML in path. Unknown options are rejected, to catch typos.
+func loadConfig(path string) (*config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cfg := &config{}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate checks that cfg has the options every run needs and exactly one mode.
+func (cfg *config) validate() error {
+	var errs []error
+	if cfg.Host == "" {
+		errs = append(errs, errors.New("the host is not set"))
+	}
+	if cfg.Local == "" {
+		errs = append(errs, errors.New("the local directory is not set"))
+	}
+	if cfg.Direction != "up" && cfg.Direction != "down" {
+		errs = append(errs, fmt.Errorf("the direction is %q, it must be up or down", cfg.Direction))
+	}
+	modes := 0
+	for _, set := range []bool{cfg.Watch, cfg.Once, cfg.DryRun} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		errs = append(errs, errors.New("exactly one of watch, once and dry-run must be set"))
+	}
+	return errors.Join(errs...)
+}
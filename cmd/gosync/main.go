@@ -0,0 +1,82 @@
+// Command gosync syncs a local directory with a directory on an FTP or SFTP server, in either direction, using the
+// ftp and sftp packages.
+//
+// Usage:
+//
+//	gosync ftp --local /path --remote /remote --host ftp.example.com --direction up --watch
+//	gosync sftp --config config.yaml
+//
+// Exactly one mode is required: --watch syncs the directories and then keeps them in sync, --once syncs them once,
+// and --dry-run prints the files a sync would transfer without transferring them. The options can be read from a
+// YAML file with --config, whose keys are the fields of the config struct:
+//
+//	host: sftp.example.com
+//	username: deploy
+//	local: ./site
+//	remote: /var/www
+//	direction: up
+//	once: true
+//
+// SIGINT and SIGTERM stop the sync in progress and close the connection before gosync exits.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "gosync:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs gosync with the command line args, the arguments following the program name, until the sync is done or
+// the process receives SIGINT or SIGTERM.
+func run(args []string, stdout, stderr io.Writer) error {
+	cfg, err := parseArgs(args, stderr)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sess, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		switch {
+		case cfg.DryRun:
+			changes, err := sess.diff(ctx)
+			for _, c := range changes {
+				_, _ = fmt.Fprintf(stdout, "%s\t%s\n", c.Reason, c.Path)
+			}
+			done <- err
+		case cfg.Once:
+			done <- sess.once(ctx)
+		default:
+			sess.watch()
+			done <- nil
+		}
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_, _ = fmt.Fprintln(stderr, "gosync: interrupted, stopping")
+	}
+	return errors.Join(err, sess.close())
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("protocol: sftp\nhost: sftp.example.com\nusername: deploy\nlocal: ./site\nremote: /var/www\ndirection: up\nonce: true\n"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write config: %s", err)
+	}
+	t.Setenv(passwordEnv, "secret")
+
+	cfg, err := parseArgs([]string{"sftp", "--config", path, "--direction", "down", "--workers", "4"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseArgs failed: %s", err)
+	}
+	want := &config{
+		Protocol:  "sftp",
+		Host:      "sftp.example.com",
+		Username:  "deploy",
+		Password:  "secret",
+		Local:     "./site",
+		Remote:    "/var/www",
+		Direction: "down",
+		Workers:   4,
+		Once:      true,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("parseArgs() = %+v, want %+v", cfg, want)
+	}
+
+	cfg, err = parseArgs([]string{"ftp", "--local", "/path", "--host", "ftp.example.com", "--direction", "up", "--watch"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseArgs failed: %s", err)
+	}
+	if cfg.Protocol != "ftp" || cfg.Remote != "/" || !cfg.Watch {
+		t.Errorf("parseArgs() = %+v, want an ftp watch of /", cfg)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ssh"}, "ftp or sftp"},
+		{[]string{"ftp", "--host", "h", "--local", "l", "--direction", "sideways", "--once"}, "direction"},
+		{[]string{"ftp", "--host", "h", "--local", "l", "--direction", "up"}, "exactly one"},
+		{[]string{"ftp", "--host", "h", "--local", "l", "--direction", "up", "--once", "--dry-run"}, "exactly one"},
+		{[]string{"ftp", "--config", path}, "not ftp"},
+		{[]string{"sftp", "--config", filepath.Join(t.TempDir(), "missing.yaml")}, "no such file"},
+	} {
+		if _, err := parseArgs(tc.args, io.Discard); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseArgs(%q) = %v, want an error containing %q", tc.args, err, tc.want)
+		}
+	}
+
+	err = os.WriteFile(path, []byte("host: h\nhots: typo\n"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write config: %s", err)
+	}
+	if _, err := parseArgs([]string{"sftp", "--config", path}, io.Discard); err == nil || !strings.Contains(err.Error(), "hots") {
+		t.Errorf("Expected an unknown option to be rejected, got %v", err)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	modified := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+	local := map[string]entry{
+		"same.txt":    {size: 1, modTime: modified},
+		"new.txt":     {size: 1, modTime: modified},
+		"resized.txt": {size: 2, modTime: modified},
+		"edited.txt":  {size: 1, modTime: modified.Add(time.Minute)},
+		"sub/ms.txt":  {size: 1, modTime: modified.Add(time.Millisecond)},
+	}
+	remote := map[string]entry{
+		"same.txt":    {size: 1, modTime: modified},
+		"resized.txt": {size: 1, modTime: modified},
+		"edited.txt":  {size: 1, modTime: modified},
+		"sub/ms.txt":  {size: 1, modTime: modified},
+		"old.txt":     {size: 1, modTime: modified},
+	}
+	want := []change{{"edited.txt", "newer"}, {"new.txt", "missing"}, {"resized.txt", "size"}}
+	if got := diffTrees("up", local, remote); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTrees(up) = %v, want %v", got, want)
+	}
+	want = []change{{"old.txt", "missing"}, {"resized.txt", "size"}}
+	if got := diffTrees("down", local, remote); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTrees(down) = %v, want %v", got, want)
+	}
+}
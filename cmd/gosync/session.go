@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/ftp"
+	"github.com/cploutarchou/syncpkg/sftp"
+)
+
+// session is a connection to the server of a gosync run.
+type session interface {
+	// watch syncs the directories, then keeps them in sync until close is called.
+	watch()
+	// once syncs the directories once.
+	once(ctx context.Context) error
+	// diff returns the files a sync in the configured direction would transfer.
+	diff(ctx context.Context) ([]change, error)
+	// close stops the sync in progress and the connection.
+	close() error
+}
+
+// change is a file a sync would transfer, see session.diff.
+type change struct {
+	//Path is the path of the file relative to the synced directories, with forward slashes
+	Path string
+	//Reason is why the file would be transferred: missing, size or newer
+	Reason string
+}
+
+// entry is the state of a file of a listed tree.
+type entry struct {
+	size    int64
+	modTime time.Time
+}
+
+// connect connects to the server of cfg.
+func connect(cfg *config) (session, error) {
+	if cfg.Protocol == "ftp" {
+		port := cfg.Port
+		if port == 0 {
+			port = 21
+		}
+		extra := ftp.NewExtraConfig(cfg.Local, cfg.Remote, cfg.Username, cfg.Password)
+		if cfg.Workers > 0 {
+			extra.Workers = cfg.Workers
+		}
+		if cfg.MaxRetries > 0 {
+			extra.MaxRetries = cfg.MaxRetries
+		}
+		direction := ftp.LocalToRemote
+		if cfg.Direction == "down" {
+			direction = ftp.RemoteToLocal
+		}
+		client, err := ftp.Connect(cfg.Host, port, direction, extra)
+		if err != nil {
+			return nil, err
+		}
+		return &ftpSession{client: client, cfg: cfg}, nil
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	extra := sftp.NewExtraConfig(cfg.Local, cfg.Remote, cfg.Username, cfg.Password)
+	if cfg.Workers > 0 {
+		extra.Workers = cfg.Workers
+	}
+	if cfg.MaxRetries > 0 {
+		extra.MaxRetries = cfg.MaxRetries
+	}
+	direction := sftp.LocalToRemote
+	if cfg.Direction == "down" {
+		direction = sftp.RemoteToLocal
+	}
+	connect := sftp.Connect
+	if cfg.Password == "" {
+		connect = sftp.ConnectSSHPair
+	}
+	client, err := connect(cfg.Host, port, direction, extra)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpSession{client: client, cfg: cfg}, nil
+}
+
+// ftpSession is the session of an FTP server.
+type ftpSession struct {
+	client *ftp.FTP
+	cfg    *config
+}
+
+func (s *ftpSession) watch() {
+	s.client.WatchDirectory()
+}
+
+// once transfers the files diff reports, since the ftp package has no one-shot sync.
+func (s *ftpSession) once(ctx context.Context) error {
+	changes, err := s.diff(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range changes {
+		localPath := filepath.Join(s.cfg.Local, filepath.FromSlash(c.Path))
+		remotePath := path.Join(s.cfg.Remote, c.Path)
+		if s.cfg.Direction == "up" {
+			err = s.client.EnsureRemoteDir(path.Dir(c.Path))
+			if err == nil {
+				err = s.client.Upload(ctx, localPath, remotePath)
+			}
+		} else {
+			err = os.MkdirAll(filepath.Dir(localPath), 0755)
+			if err == nil {
+				err = s.client.Download(ctx, remotePath, localPath)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ftpSession) diff(ctx context.Context) ([]change, error) {
+	localEntries, err := s.client.ListLocal(ctx, s.cfg.Local, true)
+	if err != nil {
+		return nil, err
+	}
+	remoteEntries, err := s.client.ListRemote(ctx, s.cfg.Remote, true)
+	if err != nil {
+		return nil, err
+	}
+	local, remote := make(map[string]entry), make(map[string]entry)
+	for _, e := range localEntries {
+		if !e.IsDir {
+			local[e.Path] = entry{size: e.Size, modTime: e.ModTime}
+		}
+	}
+	for _, e := range remoteEntries {
+		if !e.IsDir {
+			remote[e.Path] = entry{size: e.Size, modTime: e.ModTime}
+		}
+	}
+	return diffTrees(s.cfg.Direction, local, remote), nil
+}
+
+// close lets the workers finish the queued tasks. The ftp package cannot stop WatchDirectory, so the process
+// exits right after.
+func (s *ftpSession) close() error {
+	s.client.Pool.Close()
+	return nil
+}
+
+// sftpSession is the session of an SFTP server.
+type sftpSession struct {
+	client *sftp.SFTP
+	cfg    *config
+}
+
+func (s *sftpSession) watch() {
+	s.client.WatchDirectory()
+}
+
+func (s *sftpSession) once(context.Context) error {
+	return s.client.SyncOnce()
+}
+
+func (s *sftpSession) diff(ctx context.Context) ([]change, error) {
+	local, err := listLocal(ctx, s.cfg.Local)
+	if err != nil {
+		return nil, err
+	}
+	remote := make(map[string]entry)
+	walker := s.client.Client.Walk(s.cfg.Remote)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		info := walker.Stat()
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(s.cfg.Remote), filepath.FromSlash(walker.Path()))
+		if err != nil {
+			return nil, err
+		}
+		remote[filepath.ToSlash(rel)] = entry{size: info.Size(), modTime: info.ModTime()}
+	}
+	return diffTrees(s.cfg.Direction, local, remote), nil
+}
+
+func (s *sftpSession) close() error {
+	return s.client.Close()
+}
+
+// listLocal returns the regular files below dir by their slash-separated path relative to dir.
+func listLocal(ctx context.Context, dir string) (map[string]entry, error) {
+	files := make(map[string]entry)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = entry{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// diffTrees returns the files of the source tree, local for up and remote for down, that are missing from the
+// destination tree, have a different size there, or were modified after their destination copy, sorted by path.
+// Modification times are compared to the second, the precision of most listings.
+func diffTrees(direction string, local, remote map[string]entry) []change {
+	source, destination := local, remote
+	if direction == "down" {
+		source, destination = remote, local
+	}
+	var changes []change
+	for p, src := range source {
+		dst, ok := destination[p]
+		switch {
+		case !ok:
+			changes = append(changes, change{Path: p, Reason: "missing"})
+		case src.size != dst.size:
+			changes = append(changes, change{Path: p, Reason: "size"})
+		case src.modTime.Truncate(time.Second).After(dst.modTime.Truncate(time.Second)):
+			changes = append(changes, change{Path: p, Reason: "newer"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
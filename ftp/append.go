@@ -0,0 +1,110 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+)
+
+// appendCheckSize is how many bytes at the end of the remote file appendTail compares with the local file before
+// appending to it.
+const appendCheckSize = 64 * 1024
+
+// appendTail is a method of the FTP struct that uploads only the bytes a local file gained since it was last
+// uploaded, for log-style files that only grow. It appends them with APPE when the remote file is smaller than the
+// local one and its last bytes, up to appendCheckSize, match the same region of the local file. A file that was
+// rewritten rather than appended to, e.g. a rotated log, fails the check and must be uploaded whole.
+//
+// - file is the open local file.
+//
+// - remotePath is the path of the file on the FTP server.
+//
+// - Returns true if the tail was appended, false if the file must be uploaded whole, and an error if the server
+// failed while checking or appending.
+func (f *FTP) appendTail(file *os.File, remotePath string) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	conn, err := f.openRawConn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.close()
+
+	remoteSize, err := conn.size(remotePath)
+	if err != nil || remoteSize == 0 || remoteSize >= info.Size() {
+		// Missing, empty, or not smaller than the local file: nothing to append to.
+		return false, nil
+	}
+
+	checkSize := int64(appendCheckSize)
+	if remoteSize < checkSize {
+		checkSize = remoteSize
+	}
+	remoteHash := sha256.New()
+	err = conn.retrieveFrom(remotePath, remoteSize-checkSize, remoteHash)
+	if err != nil {
+		if notSupported(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	localHash := sha256.New()
+	_, err = io.Copy(localHash, io.NewSectionReader(file, remoteSize-checkSize, checkSize))
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(localHash.Sum(nil), remoteHash.Sum(nil)) {
+		logger.Debugf("%s does not start with the remote file, uploading it whole", file.Name())
+		return false, nil
+	}
+
+	err = conn.transfer("APPE", remotePath, io.NewSectionReader(file, remoteSize, info.Size()-remoteSize))
+	if err != nil {
+		if notSupported(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	logger.Debugf("Appended %d bytes to %s", info.Size()-remoteSize, remotePath)
+	return true, nil
+}
+
+// retrieveFrom downloads path from offset to its end into dest, with REST and RETR over a new data connection.
+func (r *rawConn) retrieveFrom(path string, offset int64, dest io.Writer) error {
+	_, err := r.send([]int{350}, "REST %d", offset)
+	if err != nil {
+		return err
+	}
+	getConn, err := r.conn.PrepareDataConn()
+	if err != nil {
+		return err
+	}
+	_, err = r.send([]int{125, 150}, "RETR %s", path)
+	if err != nil {
+		return err
+	}
+	dataConn, err := getConn()
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(dest, dataConn)
+	closeErr := dataConn.Close()
+
+	code, msg, err := r.conn.ReadResponse()
+	if err != nil {
+		return err
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if code != 226 && code != 250 {
+		return &replyError{code: code, msg: msg}
+	}
+	return nil
+}
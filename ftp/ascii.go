@@ -0,0 +1,98 @@
+package ftp
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultASCIIExtensions is used when ExtraConfig.ASCIIExtensions is not set.
+var defaultASCIIExtensions = map[string]bool{
+	".txt":  true,
+	".csv":  true,
+	".log":  true,
+	".json": true,
+	".xml":  true,
+	".html": true,
+	".css":  true,
+	".js":   true,
+}
+
+// isText is a method of the FTP struct that reports whether file should be uploaded in ASCII mode.
+//
+// - file is the open local file.
+//
+// The extension of the file is looked up in f.config.ASCIIExtensions first. Only files with an unlisted
+// extension have their first 512 bytes read and sniffed with http.DetectContentType.
+func (f *FTP) isText(file *os.File) bool {
+	extensions := f.config.ASCIIExtensions
+	if extensions == nil {
+		extensions = defaultASCIIExtensions
+	}
+	if text, ok := extensions[strings.ToLower(filepath.Ext(file.Name()))]; ok {
+		return text
+	}
+
+	buf := make([]byte, 512)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return strings.HasPrefix(http.DetectContentType(buf[:n]), "text/")
+}
+
+// storeASCII is a method of the FTP struct that uploads src to remotePath in ASCII mode.
+// goftp always transfers in binary mode, so the upload runs on a raw connection set to TYPE A.
+//
+// - remotePath is the path of the file on the FTP server.
+//
+// - src is the content of the file. Its line endings are converted to CRLF as required on the wire.
+//
+// - Returns an error if the connection cannot be opened or the upload fails.
+func (f *FTP) storeASCII(remotePath string, src io.Reader) error {
+	conn, err := f.openRawConnType("A")
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	return conn.transfer("STOR", remotePath, &netASCIIReader{reader: bufio.NewReader(src)})
+}
+
+// netASCIIReader converts bare LF line endings to CRLF, the line ending of ASCII mode transfers.
+type netASCIIReader struct {
+	reader    *bufio.Reader
+	prevCR    bool
+	pendingLF bool
+}
+
+func (r *netASCIIReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if r.pendingLF {
+			p[n] = '\n'
+			n++
+			r.pendingLF = false
+			continue
+		}
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b == '\n' && !r.prevCR {
+			p[n] = '\r'
+			n++
+			r.pendingLF = true
+			continue
+		}
+		r.prevCR = b == '\r'
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,295 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/secsy/goftp"
+)
+
+// replyError is returned by rawConn when the server answers a command with an unexpected reply code.
+type replyError struct {
+	code int
+	msg  string
+}
+
+func (e *replyError) Error() string {
+	return fmt.Sprintf("unexpected response: %d-%s", e.code, e.msg)
+}
+
+// notSupported reports whether err is the server rejecting a command it does not implement.
+func notSupported(err error) bool {
+	replyErr, ok := err.(*replyError)
+	if !ok {
+		return false
+	}
+	return replyErr.code == 500 || replyErr.code == 502 || replyErr.code == 504
+}
+
+// rawConn wraps a goftp.RawConn to issue commands that goftp.Client does not expose, such as APPE and REST.
+type rawConn struct {
+	conn goftp.RawConn
+}
+
+// openRawConn is a method of the FTP struct that opens a rawConn outside the client's connection pool
+// and switches it to binary transfers.
+//
+// - Returns an error if the connection cannot be opened or the server rejects binary mode.
+func (f *FTP) openRawConn() (*rawConn, error) {
+	return f.openRawConnType("I")
+}
+
+// openRawConnType is a method of the FTP struct that opens a rawConn outside the client's connection pool
+// and sets its transfer type, "I" for binary or "A" for ASCII.
+//
+// - Returns an error if the connection cannot be opened or the server rejects the transfer type.
+func (f *FTP) openRawConnType(dataType string) (*rawConn, error) {
+	conn, err := f.ftpClient().OpenRawConn()
+	if err != nil {
+		return nil, err
+	}
+	raw := &rawConn{conn: conn}
+	_, err = raw.send([]int{200}, "TYPE %s", dataType)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return raw, nil
+}
+
+// send sends the command fmt.Sprintf(format, args...) and returns the reply message.
+// It returns a *replyError if the reply code is not one of expected.
+func (r *rawConn) send(expected []int, format string, args ...interface{}) (string, error) {
+	code, msg, err := r.conn.SendCommand(format, args...)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range expected {
+		if code == c {
+			return msg, nil
+		}
+	}
+	return "", &replyError{code: code, msg: msg}
+}
+
+// size returns the size of the remote file at path using the SIZE command.
+func (r *rawConn) size(path string) (int64, error) {
+	msg, err := r.send([]int{213}, "SIZE %s", path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(msg, 10, 64)
+}
+
+// transfer runs the data command (STOR or APPE) for path and streams src over a new data connection.
+func (r *rawConn) transfer(command, path string, src io.Reader) error {
+	getConn, err := r.conn.PrepareDataConn()
+	if err != nil {
+		return err
+	}
+	_, err = r.send([]int{125, 150}, "%s %s", command, path)
+	if err != nil {
+		return err
+	}
+	dataConn, err := getConn()
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(dataConn, src)
+	closeErr := dataConn.Close()
+
+	code, msg, err := r.conn.ReadResponse()
+	if err != nil {
+		return err
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if code != 226 && code != 250 {
+		return &replyError{code: code, msg: msg}
+	}
+	return nil
+}
+
+// close closes the control and data connections of the rawConn.
+func (r *rawConn) close() {
+	_ = r.conn.Close()
+}
+
+// chunkUploadSuffix is appended to the remote path of a file uploaded in chunks until all chunks are stored. It is
+// one of the default TempFilePatterns, so the incomplete file is not synced back.
+const chunkUploadSuffix = ".part"
+
+// uploadChunked is a method of the FTP struct that uploads a file larger than ChunkSize in chunks, so no single
+// data connection stays open long enough to be dropped by idle timeouts on intermediate network devices.
+//
+// - file is the open local file to upload.
+//
+// - size is the size of the local file.
+//
+// - remotePath is the path of the file on the FTP server.
+//
+// The chunks are uploaded to remotePath with chunkUploadSuffix, which is renamed to remotePath once all of them are
+// stored, so readers never see a partial file at remotePath and a failed upload leaves the existing file untouched.
+// The first chunk is stored with STOR and every following chunk is appended with APPE, each on its own data connection.
+// A failed chunk is retried up to f.config.MaxRetries times, resuming from the size the server reports for the
+// temporary file, or from the start if it reports none.
+// If the server does not support APPE, the remaining chunks are uploaded as separate part files and assembled by uploadParts.
+//
+// - Returns an error if a chunk cannot be uploaded or the temporary file cannot be renamed.
+func (f *FTP) uploadChunked(file *os.File, size int64, remotePath string) error {
+	tempPath := remotePath + chunkUploadSuffix
+	err := f.uploadChunks(file, size, tempPath)
+	if err == nil {
+		err = f.replaceRemoteFile(tempPath, remotePath)
+	}
+	if err != nil {
+		if err := f.ftpClient().Delete(tempPath); err != nil {
+			logger.Debug("Error removing the temporary upload:", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadChunks is a method of the FTP struct that stores the chunks of file at tempPath for uploadChunked.
+//
+// - Returns an error if a chunk cannot be uploaded.
+func (f *FTP) uploadChunks(file *os.File, size int64, tempPath string) error {
+	conn, err := f.openRawConn()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		conn.close()
+	}()
+
+	var offset int64
+	attempts := 0
+	for offset < size {
+		n := f.config.ChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		command := "APPE"
+		if offset == 0 {
+			command = "STOR"
+		}
+
+		err = conn.transfer(command, tempPath, io.NewSectionReader(file, offset, n))
+		if err == nil {
+			offset += n
+			attempts = 0
+			continue
+		}
+		if offset > 0 && notSupported(err) {
+			logger.Warnf("Server does not support APPE, assembling %s from parts", tempPath)
+			return f.uploadParts(file, size, offset, tempPath)
+		}
+
+		attempts++
+		if !f.retryable(err) {
+			return err
+		}
+		if attempts >= f.config.MaxRetries {
+			return &exhaustedError{op: fmt.Sprintf("upload chunk at offset %d", offset), attempts: attempts, err: err}
+		}
+		logger.Warnf("Attempt %d/%d: Error uploading chunk at offset %d: %v", attempts, f.config.MaxRetries, offset, err)
+
+		// Reconnect and resume from what the server actually received.
+		conn.close()
+		conn, err = f.openRawConn()
+		if err != nil {
+			return err
+		}
+		offset, err = conn.size(tempPath)
+		if err != nil || offset > size {
+			offset = 0
+		}
+	}
+	return nil
+}
+
+// replaceRemoteFile is a method of the FTP struct that renames tempPath to remotePath, replacing the file at
+// remotePath. Servers that refuse to rename over an existing file get it deleted first.
+//
+// - Returns an error if the server fails to rename the file.
+func (f *FTP) replaceRemoteFile(tempPath, remotePath string) error {
+	err := f.ftpClient().Rename(tempPath, remotePath)
+	if err == nil {
+		return nil
+	}
+	if deleteErr := f.ftpClient().Delete(remotePath); deleteErr != nil {
+		return err
+	}
+	return f.ftpClient().Rename(tempPath, remotePath)
+}
+
+// uploadParts is a method of the FTP struct that uploads the rest of a file as part files when the server does not support APPE.
+//
+// - file is the open local file to upload.
+//
+// - size is the size of the local file.
+//
+// - offset is the number of bytes already stored at remotePath.
+//
+// - remotePath is the path of the incomplete file on the FTP server, the temporary file of uploadChunked.
+//
+// Each remaining chunk is stored at remotePath followed by its number, such as file.bin.part001 for file.bin.part. The
+// parts are then assembled client-side by downloading remotePath and every part with Retrieve into a temporary file,
+// which is stored back at remotePath. The part files are deleted once the file is assembled.
+//
+// - Returns an error if a part cannot be uploaded or the file cannot be assembled.
+func (f *FTP) uploadParts(file *os.File, size, offset int64, remotePath string) error {
+	var parts []string
+	for offset < size {
+		n := f.config.ChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		part := fmt.Sprintf("%s%03d", remotePath, len(parts)+1)
+		err := f.ftpClient().Store(part, io.NewSectionReader(file, offset, n))
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+		offset += n
+	}
+
+	assembled, err := os.CreateTemp("", "ftp-assemble-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = assembled.Close()
+		_ = os.Remove(assembled.Name())
+	}()
+
+	for _, p := range append([]string{remotePath}, parts...) {
+		err = f.ftpClient().Retrieve(p, assembled)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = assembled.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = f.ftpClient().Store(remotePath, assembled)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		err = f.ftpClient().Delete(p)
+		if err != nil {
+			logger.Error("Error removing part file:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package ftp
+
+import (
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// NewExtraConfig is a function that returns an ExtraConfig for syncing localDir with remoteDir as username, with the
+// defaults the zero value does not provide: 3 retries, a poll interval of 5 seconds, SyncHidden, SkipSpecialFiles,
+// and watcher events queued with worker.PriorityHigh.
+//
+// - localDir is the local directory to sync.
+//
+// - remoteDir is the remote directory to sync.
+//
+// - username and password are the credentials of the FTP account.
+//
+// - Returns the config, which the caller can adjust before passing it to Connect.
+func NewExtraConfig(localDir, remoteDir, username, password string) *ExtraConfig {
+	return &ExtraConfig{
+		Username:         username,
+		Password:         password,
+		LocalDir:         localDir,
+		RemoteDir:        remoteDir,
+		Retries:          3,
+		MaxRetries:       3,
+		PollInterval:     5 * time.Second,
+		SyncHidden:       true,
+		SkipSpecialFiles: true,
+		EventPriority:    worker.PriorityHigh,
+	}
+}
+
+// Clone is a method of the ExtraConfig struct that returns a copy of config, to derive a modified config without
+// changing the original. The ASCIIExtensions map and the TempFilePatterns, IncludeExtensions and ExcludeExtensions
+// slices are copied too; ServerLocation and Clock are shared.
+//
+// - Returns nil if config is nil.
+func (config *ExtraConfig) Clone() *ExtraConfig {
+	if config == nil {
+		return nil
+	}
+	clone := *config
+	if config.ASCIIExtensions != nil {
+		clone.ASCIIExtensions = make(map[string]bool, len(config.ASCIIExtensions))
+		for ext, text := range config.ASCIIExtensions {
+			clone.ASCIIExtensions[ext] = text
+		}
+	}
+	if config.TempFilePatterns != nil {
+		clone.TempFilePatterns = append([]string(nil), config.TempFilePatterns...)
+	}
+	if config.IncludeExtensions != nil {
+		clone.IncludeExtensions = append([]string(nil), config.IncludeExtensions...)
+	}
+	if config.ExcludeExtensions != nil {
+		clone.ExcludeExtensions = append([]string(nil), config.ExcludeExtensions...)
+	}
+	return &clone
+}
+
+// workers is a method of the ExtraConfig struct that returns the capacity of the worker pool: Workers, or 10 if
+// it is not set.
+func (config *ExtraConfig) workers() int {
+	if config.Workers > 0 {
+		return config.Workers
+	}
+	return 10
+}
+
+// poolConfig is a method of the ExtraConfig struct that returns the config of the worker pool, with
+// AdaptiveWorkers between MinWorkers and MaxWorkers.
+func (config *ExtraConfig) poolConfig() worker.PoolConfig {
+	return worker.PoolConfig{
+		AdaptiveWorkers: config.AdaptiveWorkers,
+		MinWorkers:      config.MinWorkers,
+		MaxWorkers:      config.MaxWorkers,
+	}
+}
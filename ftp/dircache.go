@@ -0,0 +1,26 @@
+package ftp
+
+import "strings"
+
+// invalidateDirCache is a method of the FTP struct that removes remotePath and every directory below it from the remote directory cache.
+//
+// - remotePath is the path of the removed remote file or directory.
+func (f *FTP) invalidateDirCache(remotePath string) {
+	remotePath = strings.TrimSuffix(remotePath, "/")
+	f.remoteDirCache.Range(func(key, _ interface{}) bool {
+		dir := key.(string)
+		if dir == remotePath || strings.HasPrefix(dir, remotePath+"/") {
+			f.remoteDirCache.Delete(dir)
+		}
+		return true
+	})
+}
+
+// ClearDirCache is a method of the FTP struct that forgets every remote directory known to exist,
+// so the next upload checks and creates its directories on the FTP server again.
+func (f *FTP) ClearDirCache() {
+	f.remoteDirCache.Range(func(key, _ interface{}) bool {
+		f.remoteDirCache.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,87 @@
+package ftp
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// EnsureRemoteDir is a method of the FTP struct that creates a directory below RemoteDir along with any missing
+// parents, for scripts that need a remote tree to exist before transferring, independently of the sync. It is
+// idempotent: a directory that already exists is left as it is.
+//
+// - relPath is the path of the directory relative to RemoteDir, with forward slashes.
+//
+// The created directories get DirMode, if set, and are cached like those of the sync, so uploads below them do not
+// check them again.
+//
+// - Returns ErrPathTraversal if relPath resolves outside RemoteDir, or an error if a component of the path cannot
+// be created and cannot be listed.
+func (f *FTP) EnsureRemoteDir(relPath string) error {
+	remotePath, err := safeJoin(f.config.RemoteDir, relPath)
+	if err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	return f.mkdirAllRemote(filepath.ToSlash(remotePath))
+}
+
+// mkdirAllRemote is a method of the FTP struct that creates remotePath and its missing parents on the FTP server.
+//
+// - remotePath is the path of the directory. A relative path is resolved from the root of the server.
+//
+// Each component not cached in f.remoteDirCache is created with f.client.Mkdir. If that fails, the component is
+// assumed to exist if it can be listed. Created components are set to DirMode with chmodRemoteDir.
+//
+// - Returns an error if a component can neither be created nor listed, or cannot be set to DirMode.
+func (f *FTP) mkdirAllRemote(remotePath string) error {
+	currentPath := "/"
+	for _, part := range strings.Split(remotePath, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		currentPath = path.Join(currentPath, part)
+		// Skip the directories already known to exist
+		if _, ok := f.remoteDirCache.Load(currentPath); ok {
+			continue
+		}
+		// First, try to make the directory
+		_, err := f.ftpClient().Mkdir(currentPath)
+		if err != nil {
+			// If that fails, assume it's because the directory already exists and check it
+			_, err := f.ftpClient().ReadDir(currentPath)
+			if err != nil {
+				return err
+			}
+		} else if err := f.chmodRemoteDir(currentPath); err != nil {
+			return err
+		}
+		f.remoteDirCache.Store(currentPath, struct{}{})
+	}
+	return nil
+}
+
+// chmodRemoteDir is a method of the FTP struct that sets a remote directory to DirMode with SITE CHMOD. It does
+// nothing if DirMode is zero, and only logs a notice if the server does not support SITE CHMOD.
+//
+// - remotePath is the path of the directory on the FTP server.
+//
+// - Returns an error if the server cannot be reached or rejects the command for another reason.
+func (f *FTP) chmodRemoteDir(remotePath string) error {
+	mode := f.config.DirMode.Perm()
+	if mode == 0 {
+		return nil
+	}
+	conn, err := f.openRawConn()
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	_, err = conn.send([]int{200}, "SITE CHMOD %o %s", mode, remotePath)
+	if notSupported(err) {
+		logger.Debug("The server does not support SITE CHMOD, leaving the mode of", remotePath)
+		return nil
+	}
+	return err
+}
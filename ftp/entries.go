@@ -0,0 +1,112 @@
+package ftp
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RemoteEntry describes a file or directory listed by ListRemote or ListLocal, so the local and remote trees can be
+// compared with a common type.
+type RemoteEntry struct {
+	Path    string      // Path is the path of the entry relative to the listed directory, with forward slashes.
+	Size    int64       // Size is the size of a file in bytes, and 0 for directories.
+	ModTime time.Time   // ModTime is the modification time, at the precision of the listing.
+	Mode    os.FileMode // Mode holds the permission bits, or 0 if the server did not report them.
+	IsDir   bool        // IsDir is true for directories.
+}
+
+// ListRemote is a method of the FTP struct that lists the files and directories below a remote directory, in lexical
+// order within each directory.
+//
+// - ctx stops the listing between two entries; ListRemote then returns ctx.Err().
+//
+// - remotePath is the remote directory to list. It is not part of the result.
+//
+// - recursive lists the subdirectories too; otherwise only the entries of remotePath are returned.
+//
+// - Returns the entries, or an error if a directory cannot be listed.
+func (f *FTP) ListRemote(ctx context.Context, remotePath string, recursive bool) ([]RemoteEntry, error) {
+	var entries []RemoteEntry
+	err := f.Walk(ctx, remotePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == remotePath {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(remotePath), filepath.FromSlash(path))
+		if err != nil {
+			return err
+		}
+		mode, _ := remoteMode(info)
+		entries = append(entries, newRemoteEntry(rel, info, mode))
+		if info.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListLocal is a method of the FTP struct that lists the files and directories below a local directory like
+// ListRemote, so the result can be compared with the listing of the remote directory it is synced with.
+//
+// - ctx stops the listing between two entries; ListLocal then returns ctx.Err().
+//
+// - localPath is the local directory to list. It is not part of the result.
+//
+// - recursive lists the subdirectories too; otherwise only the entries of localPath are returned.
+//
+// - Returns the entries, or an error if a directory cannot be read.
+func (f *FTP) ListLocal(ctx context.Context, localPath string, recursive bool) ([]RemoteEntry, error) {
+	var entries []RemoteEntry
+	err := filepath.WalkDir(localPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == localPath {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, newRemoteEntry(rel, info, info.Mode().Perm()))
+		if d.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// newRemoteEntry is a function that returns the RemoteEntry of a listed file or directory.
+//
+// - rel is the path of the entry relative to the listed directory.
+//
+// - info is the FileInfo of the entry.
+//
+// - mode holds the permission bits of the entry.
+func newRemoteEntry(rel string, info os.FileInfo, mode os.FileMode) RemoteEntry {
+	entry := RemoteEntry{Path: filepath.ToSlash(rel), ModTime: info.ModTime(), Mode: mode, IsDir: info.IsDir()}
+	if !entry.IsDir {
+		entry.Size = info.Size()
+	}
+	return entry
+}
@@ -0,0 +1,42 @@
+package ftp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FilterByEventType is a function that returns an ExtraConfig.EventFilter accepting the events that have any of ops,
+// e.g. FilterByEventType(fsnotify.Create, fsnotify.Write) to propagate changes but not removals.
+//
+// - ops are the event types to accept.
+func FilterByEventType(ops ...fsnotify.Op) func(fsnotify.Event) bool {
+	var mask fsnotify.Op
+	for _, op := range ops {
+		mask |= op
+	}
+	return func(event fsnotify.Event) bool {
+		return event.Op&mask != 0
+	}
+}
+
+// FilterByExtension is a function that returns an ExtraConfig.EventFilter accepting the events for files with any of
+// exts as extension, compared case-insensitively.
+//
+// - exts are the extensions to accept, with or without the leading dot, e.g. ".csv" or "csv".
+func FilterByExtension(exts ...string) func(fsnotify.Event) bool {
+	accepted := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		accepted["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return func(event fsnotify.Event) bool {
+		return accepted[strings.ToLower(filepath.Ext(event.Name))]
+	}
+}
+
+// acceptEvent is a method of the FTP struct that reports whether an event reported by the fsnotify watcher passes
+// f.config.EventFilter. All events pass when no filter is set.
+func (f *FTP) acceptEvent(event fsnotify.Event) bool {
+	return f.config.EventFilter == nil || f.config.EventFilter(event)
+}
@@ -2,6 +2,7 @@ package ftp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -16,7 +17,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-var logger = log.New(os.Stdout, "ftp: ", log.Lshortfile)
+var logger = levelLogger{log.New(os.Stdout, "ftp: ", log.Lshortfile)}
 
 // SyncDirection is the direction of the sync (LocalToRemote or RemoteToLocal)
 type SyncDirection int
@@ -33,6 +34,12 @@ type FTP struct {
 	sync.Mutex
 	//client is the ftp client that is used to connect to the ftp server
 	client *goftp.Client
+	//clientMu guards client, which is replaced when reconnecting
+	clientMu sync.RWMutex
+	//reconnectMu serializes reconnect attempts of the workers
+	reconnectMu sync.Mutex
+	//address is the host:port of the ftp server, used to reconnect
+	address string
 	//Direction is the direction of the sync (LocalToRemote or RemoteToLocal)
 	Direction SyncDirection
 	//config is the struct that holds the extra config for the ftp connection
@@ -43,6 +50,22 @@ type FTP struct {
 	Pool *worker.Pool
 	//ctx is the context that is used to cancel the watcher
 	ctx context.Context
+	//remoteDirCache holds the remote directories known to exist, so checkOrCreateDir skips them
+	remoteDirCache sync.Map
+	//journal records the queued tasks until they complete, if JournalPath is set
+	journal *worker.Journal
+	//queued counts the tasks queued by enqueue until a worker finishes them, see replayJournal
+	queued queuedTasks
+	//renamePending holds the old names of renamed files, guarded by the Mutex, until the Create event of the new name
+	renamePending map[string]time.Time
+	//listingMu guards listing and listingClient
+	listingMu sync.Mutex
+	//listing is the ListingMode negotiated with the server on listingClient
+	listing ListingMode
+	//listingClient is the client listing was negotiated on; when Reconnect replaces the client, the mode is negotiated again
+	listingClient *goftp.Client
+	//syncErrs holds the errors the running initial sync skipped past, see ExtraConfig.OnError
+	syncErrs []error
 }
 
 // ExtraConfig is the struct that holds the extra config for the ftp connection
@@ -59,6 +82,91 @@ type ExtraConfig struct {
 	Retries int
 	//MaxRetries is the number of retries that the ftp client will try to upload/download a file
 	MaxRetries int
+	//WatchBackend selects how local changes are detected in LocalToRemote mode (defaults to WatchAuto)
+	WatchBackend WatchBackend
+	//EventPriority is the priority of the tasks queued for the changes reported by the watcher. NewExtraConfig sets
+	//worker.PriorityHigh, so edits are transferred ahead of a running initial sync; the zero value queues them in
+	//order with the rest. The initial sync and the poller always queue their tasks with worker.PriorityNormal
+	EventPriority worker.Priority
+	//PollInterval is the interval between directory snapshots when polling (defaults to 1 second)
+	PollInterval time.Duration
+	//ChunkSize is the size of the chunks that files larger than it are uploaded in (0 disables chunking)
+	ChunkSize int64
+	//MaxReconnectAttempts is the number of times a worker tries to reconnect after losing the connection (0 disables reconnecting)
+	MaxReconnectAttempts int
+	//ReconnectBackoff is the delay before the first reconnect attempt, doubled after every failed attempt (defaults to 1 second)
+	ReconnectBackoff time.Duration
+	//SkipInitialSync starts watching without the initial sync, for trees already known to be in agreement.
+	//The watcher's first snapshot becomes the baseline, so only subsequent changes are transferred
+	SkipInitialSync bool
+	//AutoASCII uploads text files in ASCII mode (TYPE A), so the server converts their line endings; other files are uploaded in binary mode
+	AutoASCII bool
+	//ASCIIExtensions maps file extensions (with the leading dot) to whether they are text, skipping content sniffing for them
+	//(defaults to common text formats such as .txt, .csv and .json)
+	ASCIIExtensions map[string]bool
+	//TempFilePatterns are the patterns of temporary files that are never synced (defaults to .swp, .swpx, ~, .tmp and .part suffixes).
+	//A pattern is a name suffix or, if it contains *, ? or [, a glob matched against the base name.
+	//Setting it to an empty, non-nil slice disables the defaults
+	TempFilePatterns []string
+	//SyncHidden syncs the files and directories whose name begins with a dot, like .git or .DS_Store. NewExtraConfig
+	//sets it; when false, the initial sync, the watcher and the poller skip every path with such a component
+	SyncHidden bool
+	//JournalPath is the file queued tasks are recorded in until they complete, so the ones pending when the connection
+	//was lost or the process stopped can be replayed with ReplayJournal (empty disables the journal)
+	JournalPath string
+	//PinnedCertSHA256 is the SHA-256 fingerprint, in hex with or without colons, of the certificate the server must present.
+	//Setting it connects with explicit FTPS (AUTH TLS) and trusts only that certificate instead of the system CA pool
+	PinnedCertSHA256 string
+	//ServerLocation is the time zone of the timestamps in LIST replies, used with servers that do not support MLSD (defaults to UTC).
+	//See FTP.ListingMode
+	ServerLocation *time.Location
+	//OnError selects whether the initial sync stops at the first file or directory it fails to sync, or logs or collects
+	//the error and goes on with the others (defaults to OnErrorAbort)
+	OnError ErrorPolicy
+	//Clock is the source of time of the pollers, the rename window and the reconnect backoff (defaults to worker.RealClock).
+	//Tests set it to a worker.FakeClock
+	Clock worker.Clock
+	//AppendGrowingFiles uploads only the new tail of a file that grew since it was uploaded, with APPE, for log-style
+	//files. The end of the remote file is checked against the local file first; a file that was rewritten is uploaded whole
+	AppendGrowingFiles bool
+	//PreserveRemoteMode gives downloaded files the permissions of the remote files
+	PreserveRemoteMode bool
+	//DefaultFileMode is the permissions PreserveRemoteMode gives downloaded files when the server does not report those
+	//of the remote file, as with MLSD listings without the UNIX.mode fact (0 keeps the permissions of new files)
+	DefaultFileMode os.FileMode
+	//EventFilter decides which events of the fsnotify watcher are synced: events for which it returns false are
+	//skipped. See FilterByEventType and FilterByExtension (nil syncs all events)
+	EventFilter func(event fsnotify.Event) bool
+	//RetryClassifier reports whether a failed transfer is attempted again, up to MaxRetries times; transfers failing
+	//with an error it returns false for fail right away (nil uses IsTransient)
+	RetryClassifier func(err error) bool
+	//SkipSpecialFiles skips FIFOs, sockets and device files with a notice; without it, the sync fails on them with
+	//ErrSpecialFile. They are never opened, since opening a FIFO blocks. NewExtraConfig sets it
+	SkipSpecialFiles bool
+	//LogLevel is applied with SetLogLevel when the connection is made, unless it is Debug, the default. The level is
+	//shared by all the connections of the package
+	LogLevel LogLevel
+	//DirMode is the permission bits set with SITE CHMOD on the remote directories created by the sync and
+	//EnsureRemoteDir. Zero leaves them to the server, as do servers that do not support SITE CHMOD
+	DirMode os.FileMode
+	//Workers is the capacity of the worker pool, the number of tasks processed at once. It defaults to 10
+	Workers int
+	//AdaptiveWorkers makes the worker pool adjust the number of tasks processed at once to the transfers: it starts
+	//at MinWorkers, backs off when transfers fail or slow down, and grows up to MaxWorkers while they are fast and
+	//tasks are queued
+	AdaptiveWorkers bool
+	//MinWorkers is the number of tasks processed at once AdaptiveWorkers starts with and never goes below. It
+	//defaults to 1
+	MinWorkers int
+	//MaxWorkers is the number of tasks processed at once AdaptiveWorkers never goes above. It defaults to Workers
+	MaxWorkers int
+	//IncludeExtensions limits the sync to the files with one of these extensions, matched against filepath.Ext
+	//case-insensitively, with or without the leading dot, e.g. []string{".jpg", "png"}. Directories are always
+	//synced. If empty, the files of any extension are synced
+	IncludeExtensions []string
+	//ExcludeExtensions are the extensions of the files never synced, matched like IncludeExtensions, which they
+	//take precedence over, e.g. []string{".tmp", ".lock"}
+	ExcludeExtensions []string
 }
 
 // Connect is a function used to establish a connection to an FTP server and return an FTP client for file synchronization.
@@ -71,14 +179,17 @@ type ExtraConfig struct {
 //
 //   - config is a pointer to the ExtraConfig struct that holds additional configuration settings for the FTP connection,
 //     including FTP server credentials (username and password), local and remote directories, and synchronization retries.
+//     It is checked with ExtraConfig.Validate before connecting.
+//
+// With SetGlobalConnectionRate, Connect waits until the rate allows a new connection.
 //
 // Example:
 //
 //	ftp, err := ftp.Connect("localhost", 21, ftp.LocalToRemote, &ftp.ExtraConfig{
 //	    Username:   "username",
 //	    Password:   "password",
-//	    LocalDir:   "localDir",
-//	    RemoteDir:  "remoteDir",
+//	    LocalDir:   "/path/to/localDir",
+//	    RemoteDir:  "/path/to/remoteDir",
 //	    Retries:    3,
 //	    MaxRetries: 3,
 //	})
@@ -89,25 +200,34 @@ type ExtraConfig struct {
 func Connect(address string, port int, direction SyncDirection, config *ExtraConfig) (*FTP, error) {
 	address = fmt.Sprintf("%s:%d", address, port)
 
-	ftpConfig := goftp.Config{
-		User:     config.Username,
-		Password: config.Password,
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if config.LogLevel != Debug {
+		SetLogLevel(config.LogLevel)
+	}
+	journal, err := openJournal(config)
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := goftp.DialConfig(ftpConfig, address)
+	client, err := dial(context.Background(), address, config)
 	if err != nil {
 		return nil, err
 	}
 
 	ftp := &FTP{
 		client:    client,
+		journal:   journal,
+		address:   address,
 		Direction: direction,
 		ctx:       context.Background(),
-		Pool:      worker.NewWorkerPool(10),
+		Pool:      worker.NewWorkerPoolWithConfig(config.workers(), config.poolConfig()),
 	}
 	ftp.config = config
 
-	logger.Println("Connected to FTP server.")
+	logger.Info("Connected to FTP server.")
 	return ftp, nil
 }
 
@@ -117,9 +237,16 @@ func Connect(address string, port int, direction SyncDirection, config *ExtraCon
 // This method is used internally to synchronize the directories when the FTP connection is initially established.
 // The synchronization direction is determined by the value of f.Direction, which can be either LocalToRemote or RemoteToLocal.
 //
-// - Returns an error if any error occurs during the synchronization process.
+// - Returns an error if any error occurs during the synchronization process. With OnErrorContinue or OnErrorCollect,
+// the entries that fail are skipped and only the errors collected with OnErrorCollect are returned.
 func (f *FTP) initialSync() error {
-	return f.syncDir(f.config.LocalDir, f.config.RemoteDir)
+	f.syncErrs = nil
+	defer func() { f.syncErrs = nil }()
+	err := f.syncDir(f.config.LocalDir, f.config.RemoteDir)
+	if err != nil {
+		return err
+	}
+	return f.syncErrors()
 }
 
 // syncDir is a method of the FTP struct that synchronizes files between the local directory and the remote directory.
@@ -141,7 +268,7 @@ func (f *FTP) initialSync() error {
 //
 // This method is used internally by the synchronization process and is not intended to be called directly.
 func (f *FTP) syncDir(localDir, remoteDir string) error {
-	logger.Println("syncDir localDir", localDir)
+	logger.Debug("syncDir localDir", localDir)
 	switch f.Direction {
 	case LocalToRemote:
 		localFiles, err := os.ReadDir(localDir)
@@ -149,30 +276,46 @@ func (f *FTP) syncDir(localDir, remoteDir string) error {
 			return err
 		}
 		for _, file := range localFiles {
+			if !f.config.SyncHidden && isHiddenName(file.Name()) {
+				continue
+			}
+			if !file.IsDir() && f.extensionFiltered(file.Name()) {
+				continue
+			}
 			localFilePath := filepath.Join(localDir, file.Name())
 			remoteFilePath := filepath.Join(remoteDir, file.Name())
 			if file.IsDir() {
 				err = f.checkOrCreateDir(remoteFilePath)
-				if err != nil {
-					return err
+				if err == nil {
+					err = f.syncDir(localFilePath, remoteFilePath)
 				}
-				err = f.syncDir(localFilePath, remoteFilePath)
-				if err != nil {
+				if err != nil && f.abortSync(localFilePath, err) {
 					return err
 				}
 			} else {
+				if info, err := file.Info(); err == nil {
+					if skip, err := f.skipSpecial(localFilePath, info.Mode()); skip {
+						if err != nil && f.abortSync(localFilePath, err) {
+							return err
+						}
+						continue
+					}
+				}
 				// stat remote file and if it doesn't exist upload it to the server
-				_, err = f.client.Stat(remoteFilePath)
+				_, err = f.ftpClient().Stat(remoteFilePath)
 				if err != nil {
 					localFile, err := os.Open(localFilePath)
 					if err != nil {
-						return err
+						if f.abortSync(localFilePath, err) {
+							return err
+						}
+						continue
 					}
 					defer func(localFile *os.File) {
 						_ = localFile.Close()
 					}(localFile)
-					err = f.client.Store(remoteFilePath, localFile)
-					if err != nil {
+					err = f.ftpClient().Store(remoteFilePath, localFile)
+					if err != nil && f.abortSync(localFilePath, err) {
 						return err
 					}
 				}
@@ -180,20 +323,29 @@ func (f *FTP) syncDir(localDir, remoteDir string) error {
 		}
 	case RemoteToLocal:
 		// Read the remote directory and all subdirectories.
-		remoteFiles, err := f.client.ReadDir(remoteDir)
+		remoteFiles, err := f.ftpClient().ReadDir(remoteDir)
 		if err != nil {
 			return err
 		}
 		for _, file := range remoteFiles {
+			if isDotEntry(file.Name()) || !f.config.SyncHidden && isHiddenName(file.Name()) {
+				continue
+			}
+			if !file.IsDir() && f.extensionFiltered(file.Name()) {
+				continue
+			}
+			localFilePath, err := safeJoin(localDir, file.Name())
+			if err != nil {
+				logger.Warn("Skipping remote file:", err)
+				continue
+			}
 			remoteFilePath := filepath.Join(remoteDir, file.Name())
-			localFilePath := filepath.Join(localDir, file.Name())
 			if file.IsDir() {
 				err = f.checkOrCreateDir(localFilePath)
-				if err != nil {
-					return err
+				if err == nil {
+					err = f.syncDir(localFilePath, remoteFilePath)
 				}
-				err = f.syncDir(localFilePath, remoteFilePath)
-				if err != nil {
+				if err != nil && f.abortSync(remoteFilePath, err) {
 					return err
 				}
 			} else {
@@ -202,15 +354,24 @@ func (f *FTP) syncDir(localDir, remoteDir string) error {
 				if os.IsNotExist(err) {
 					localFile, err := os.Create(localFilePath)
 					if err != nil {
-						return err
+						if f.abortSync(remoteFilePath, err) {
+							return err
+						}
+						continue
 					}
 					defer func(localFile *os.File) {
 						_ = localFile.Close()
 					}(localFile)
-					err = f.client.Retrieve(remoteFilePath, localFile)
+					err = f.ftpClient().Retrieve(remoteFilePath, localFile)
 					if err != nil {
-						return err
+						// Remove the partial file, which would otherwise keep the next sync from downloading it again.
+						_ = os.Remove(localFilePath)
+						if f.abortSync(remoteFilePath, err) {
+							return err
+						}
+						continue
 					}
+					f.applyRemoteMode(localFile, remoteFilePath, file)
 				}
 			}
 		}
@@ -220,7 +381,7 @@ func (f *FTP) syncDir(localDir, remoteDir string) error {
 
 // WatchDirectory is a method of the FTP struct that sets up a file system watcher to monitor changes in the local directory.
 // It starts a worker pool and performs an initial synchronization between the local directory and the remote directory
-// based on the specified synchronization direction (LocalToRemote or RemoteToLocal).
+// based on the specified synchronization direction (LocalToRemote or RemoteToLocal), unless SkipInitialSync is set.
 //
 // The method uses fsnotify package to monitor file system events such as file creations, modifications, and deletions.
 // When a file system event is detected, it creates a worker task and adds it to the worker pool for processing.
@@ -233,53 +394,77 @@ func (f *FTP) syncDir(localDir, remoteDir string) error {
 //     The method will block until the context is done or an error occurs during the synchronization process.
 func (f *FTP) WatchDirectory() {
 	// Starting the worker pool
-	for i := 0; i < cap(f.Pool.Tasks); i++ {
-		go f.Worker()
-	}
-	logger.Println("Starting initial sync...")
-	err := f.initialSync()
-	if err != nil {
-		logger.Fatal(err)
+	f.Pool.Start(cap(f.Pool.Tasks), f.Worker)
+	defer f.Pool.Stop()
+	var err error
+	if f.config.SkipInitialSync {
+		logger.Info("Skipping initial sync, only subsequent changes will be synced.")
+	} else {
+		logger.Info("Starting initial sync...")
+		err = f.initialSync()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Info("Initial sync done.")
 	}
-	logger.Println("Initial sync done.")
 
-	logger.Println("Setting up watcher...")
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		logger.Fatal(err)
+	logger.Info("Setting up watcher...")
+	watcher, watcherErr := fsnotify.NewWatcher()
+	if watcherErr != nil && !f.canPoll() {
+		logger.Fatal(watcherErr)
 	}
-	defer func(watcher *fsnotify.Watcher) {
-		_ = watcher.Close()
-	}(watcher) // Moved defer to here.
-
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				logger.Println("Received event:", event)
+	probed := make(chan struct{}, 1)
+	if watcher != nil {
+		defer func(watcher *fsnotify.Watcher) {
+			_ = watcher.Close()
+		}(watcher) // Moved defer to here.
+
+		go func() {
+			for {
+				select {
+				case event, ok := <-watcher.Events:
+					if !ok {
+						return
+					}
+					if filepath.Base(event.Name) == probeFileName {
+						select {
+						case probed <- struct{}{}:
+						default:
+						}
+						continue
+					}
+					logger.Debug("Received event:", event)
+					if !f.acceptEvent(event) || f.extensionFilteredEvent(event) {
+						logger.Debug("Skipping filtered event:", event)
+						continue
+					}
+					if f.specialEvent(event) {
+						continue
+					}
 
-				f.Pool.WG.Add(1)
-				f.Pool.Tasks <- worker.Task{EventType: event.Op, Name: event.Name}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
+					f.enqueue(worker.Task{EventType: event.Op, Name: event.Name, Priority: f.config.EventPriority})
+				case err, ok := <-watcher.Errors:
+					if !ok {
+						return
+					}
+					logger.Error("Error:", err)
 				}
-				logger.Println("Error:", err)
 			}
-		}
-	}()
+		}()
+	}
 
 	// Add root directory and all subdirectories to the watcher
-	err = f.AddDirectoriesToWatcher(watcher, f.config.LocalDir)
+	if f.Direction == LocalToRemote {
+		err = f.watchLocal(watcher, watcherErr, probed)
+	} else {
+		err = f.AddDirectoriesToWatcher(watcher, f.config.LocalDir)
+	}
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	<-f.ctx.Done()
-	logger.Println("Directory watch ended.")
+	logger.Info("Directory watch ended.")
 }
 
 // uploadFile is a method of the FTP struct that uploads a file to the remote FTP server.
@@ -291,45 +476,18 @@ func (f *FTP) WatchDirectory() {
 //
 // The method calculates the remote file path based on the local file path and the remote directory specified in f.config.RemoteDir.
 // It then opens the local file for reading and uploads it to the FTP server using the f.client.Store method.
+// Files larger than f.config.ChunkSize are uploaded in chunks by uploadChunked instead.
+// With f.config.AutoASCII set, text files are uploaded in ASCII mode by storeASCII.
 //
 // - Returns an error if the file upload fails after the maximum number of retries.
 func (f *FTP) uploadFile(filePath string) error {
-	// Open the file for reading
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
-
-	// Try to upload the file for MaxRetries times
-	for i := 0; i < f.config.MaxRetries; i++ {
-		// Calculate the remote file path
-		correctedFilePath := strings.Replace(filePath, f.config.LocalDir, "", 1)
-		correctedFilePath = filepath.Join(f.config.RemoteDir, correctedFilePath)
-
-		// Reset the file pointer to the beginning of the file
-		_, err = file.Seek(0, 0)
-		if err != nil {
-			return err
-		}
-
-		// Upload the file to the FTP server
-		err = f.client.Store(correctedFilePath, file)
-		if err != nil {
-			// If upload fails, log the error and try again
-			logger.Printf("Attempt %d/%d: Error uploading file: %v", i+1, f.config.MaxRetries, err)
-			continue
-		} else {
-			// If upload succeeds, log the success and return nil
-			logger.Printf("Uploaded file: %s", filePath)
-			return nil
-		}
+	if f.ignored(filePath) {
+		return nil
 	}
-
-	// If we reach this point, all attempts to upload the file have failed
-	return fmt.Errorf("failed to upload file after %d attempts", f.config.MaxRetries)
+	// Calculate the remote file path
+	correctedFilePath := strings.Replace(filePath, f.config.LocalDir, "", 1)
+	correctedFilePath = filepath.Join(f.config.RemoteDir, correctedFilePath)
+	return f.store(context.Background(), filePath, correctedFilePath)
 }
 
 // downloadFile is a method of the FTP struct that downloads a file from the remote FTP server to the local file system.
@@ -342,39 +500,54 @@ func (f *FTP) uploadFile(filePath string) error {
 // The method calculates the remote file path based on the file name and the remote directory specified in f.config.RemoteDir.
 // It then creates a new local file and downloads the remote file from the FTP server using the f.client.Retrieve method.
 //
-// - Returns an error if the file download fails after the maximum number of retries.
+// - Returns an error if the file download fails after the maximum number of retries, or ErrPathTraversal without downloading
+// if name would resolve outside f.config.LocalDir.
 func (f *FTP) downloadFile(name string) error {
-	f.Lock()
-	defer f.Unlock()
-
-	// Create the local file
-	file, err := os.Create(filepath.Join(f.config.LocalDir, name))
+	if f.ignored(name) {
+		return nil
+	}
+	localPath, err := safeJoin(f.config.LocalDir, name)
 	if err != nil {
 		return err
 	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
-
-	for i := 0; i < f.config.MaxRetries; i++ {
-		// Calculate the remote file path
-		remotePath := filepath.Join(f.config.RemoteDir, name)
+	f.Lock()
+	defer f.Unlock()
+	return f.retrieve(context.Background(), filepath.Join(f.config.RemoteDir, name), localPath)
+}
 
-		// Download the file from the FTP server
-		err = f.client.Retrieve(remotePath, file)
-		if err != nil {
-			// If download fails, log the error and try again
-			logger.Printf("Attempt %d/%d: Error downloading file: %v", i+1, f.config.MaxRetries, err)
-			continue
-		} else {
-			// If download succeeds, log the success and return nil
-			logger.Printf("Downloaded file: %s", name)
-			return nil
-		}
+// DirExists is a method of the FTP struct that reports whether the directory remotePath exists on the FTP server.
+//
+// - ctx is the context of the call. DirExists returns ctx.Err() if it is done before the server answers.
+//
+// - remotePath is the path of the directory on the FTP server.
+//
+// The method lists the directory with f.client.ReadDir. A permanent 550 reply (file unavailable) means the directory does not exist.
+//
+// - Returns (false, nil) if the directory does not exist and (false, err) if the server could not be queried.
+func (f *FTP) DirExists(ctx context.Context, remotePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := f.ftpClient().ReadDir(remotePath)
+		errCh <- err
+	}()
 
-	// If we reach this point, all attempts to download the file have failed
-	return fmt.Errorf("failed to download file after %d attempts", f.config.MaxRetries)
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	if err == nil {
+		return true, nil
+	}
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code() == 550 {
+		return false, nil
+	}
+	return false, err
 }
 
 // removeRemoteFile is a method of the FTP struct that deletes a file from the remote FTP server.
@@ -393,10 +566,12 @@ func (f *FTP) removeRemoteFile(filePath string) error {
 	remotePath := strings.Replace(filePath, f.config.LocalDir, f.config.RemoteDir, 1)
 
 	// Delete the file from the FTP server
-	err := f.client.Delete(remotePath)
+	err := f.ftpClient().Delete(remotePath)
 	if err != nil {
 		return err
 	}
+	// The path may have been a directory, so forget it and everything below it
+	f.invalidateDirCache(remotePath)
 
 	return nil
 }
@@ -442,11 +617,14 @@ func (f *FTP) AddDirectoriesToWatcher(watcher *fsnotify.Watcher, rootDir string)
 	case LocalToRemote:
 		return filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 			if info.IsDir() {
+				if path != rootDir && f.isHidden(path) {
+					return filepath.SkipDir
+				}
 				err = watcher.Add(path)
 				if err != nil {
 					return err
 				}
-				logger.Println("Adding watcher to directory:", path)
+				logger.Debug("Adding watcher to directory:", path)
 			}
 			return nil
 		})
@@ -461,32 +639,31 @@ func (f *FTP) AddDirectoriesToWatcher(watcher *fsnotify.Watcher, rootDir string)
 			}
 			// Check for new or removed files.
 			if prevFiles != nil {
+				mode := f.ListingMode()
 				for p, file := range newFiles {
+					if f.extensionFiltered(p) {
+						continue
+					}
 					prevFile, exists := prevFiles[p]
-					if !exists || prevFile.ModTime().Before(file.ModTime()) {
-						f.Pool.WG.Add(1)
-						f.Pool.Tasks <- worker.Task{EventType: fsnotify.Write, Name: p}
+					if !exists || remoteChanged(prevFile, file, mode) {
+						f.enqueue(worker.Task{EventType: fsnotify.Write, Name: p})
 					}
 				}
 				for p := range prevFiles {
 					_, exists := newFiles[p]
-					if !exists {
-						f.Pool.WG.Add(1)
-						f.Pool.Tasks <- worker.Task{EventType: fsnotify.Remove, Name: p}
-						logger.Println("File removed:", p)
+					if !exists && !f.extensionFiltered(p) {
+						f.enqueue(worker.Task{EventType: fsnotify.Remove, Name: p})
+						logger.Debug("File removed:", p)
 					}
 				}
 			}
 			prevFiles = newFiles
 
-			// TODO : Add a condition to stop the infinite loop.
-			// For instance, if the context (f.ctx) has been canceled:
+			// Wait for a while before checking again, until the context (f.ctx) is canceled.
 			select {
 			case <-f.ctx.Done():
 				return nil
-			default:
-				// Wait for a while before checking again.
-				time.Sleep(time.Second * 1)
+			case <-f.clock().After(f.pollInterval()):
 			}
 		}
 	}
@@ -495,23 +672,29 @@ func (f *FTP) AddDirectoriesToWatcher(watcher *fsnotify.Watcher, rootDir string)
 
 // Stat is a method of the FTP struct that retrieves file information (os.FileInfo) for a remote file on the FTP server.
 //
-// - path is the path of the remote file for which file information is required.
+// - path is the path of the local file whose remote copy the file information is required for.
 //
-// The method calculates the remote file path by joining the remote directory (f.config.RemoteDir) with the base name of the specified path.
+// The method calculates the remote file path by joining the remote directory (f.config.RemoteDir) with the specified
+// path relative to the local directory (f.config.LocalDir), as uploadFile does.
 // It then fetches the file information from the FTP server using the f.client.Stat method.
 //
 // - Returns the file information (os.FileInfo) for the remote file if the operation is successful.
 //
-// - Returns an error if there is a problem retrieving the file information from the FTP server.
+// - Returns an error if path cannot be made relative to the local directory or if there is a problem retrieving the
+// file information from the FTP server.
 func (f *FTP) Stat(path string) (os.FileInfo, error) {
 	f.Lock()
 	defer f.Unlock()
 
 	// Calculate the remote file path
-	remotePath := filepath.Join(f.config.RemoteDir, filepath.Base(path))
+	relativePath, err := filepath.Rel(f.config.LocalDir, path)
+	if err != nil {
+		return nil, err
+	}
+	remotePath := filepath.Join(f.config.RemoteDir, relativePath)
 
 	// Fetch the file info from the FTP server
-	fileInfo, err := f.client.Stat(remotePath)
+	fileInfo, err := f.ftpClient().Stat(remotePath)
 	if err != nil {
 		return nil, err
 	}
@@ -532,23 +715,32 @@ func (f *FTP) Stat(path string) (os.FileInfo, error) {
 // Note: The provided map (files) should be initialized before calling this method to collect the file information. The method only collects file information and does not modify the map if it already contains data.
 func (f *FTP) walkRemoteDir(dir string, files map[string]os.FileInfo) error {
 	// Use the ReadDir to list the contents of the directory.
-	fileInfos, err := f.client.ReadDir(dir)
+	fileInfos, err := f.ftpClient().ReadDir(dir)
 	if err != nil {
 		return err
 	}
 
 	for _, fileInfo := range fileInfos {
+		if isDotEntry(fileInfo.Name()) {
+			continue
+		}
+		// Skip the names that would resolve outside dir, such as "../name".
+		join, err := safeJoin(dir, fileInfo.Name())
+		if err != nil {
+			logger.Warn("Skipping remote file:", err)
+			continue
+		}
 		// Check if the fileInfo represents a file or a directory.
 		if fileInfo.IsDir() {
 			// If it's a directory, add it to the files map and recursively call walkRemoteDir.
-			files[filepath.Join(dir, fileInfo.Name())] = fileInfo
-			err = f.walkRemoteDir(filepath.Join(dir, fileInfo.Name()), files)
+			files[join] = fileInfo
+			err = f.walkRemoteDir(join, files)
 			if err != nil {
 				return err
 			}
 		} else {
 			// If it's a file, add it to the files map.
-			files[filepath.Join(dir, fileInfo.Name())] = fileInfo
+			files[join] = fileInfo
 		}
 	}
 
@@ -559,44 +751,19 @@ func (f *FTP) walkRemoteDir(dir string, files map[string]os.FileInfo) error {
 //
 // - dirPath is the path of the directory to be checked and created (if necessary).
 //
-// The method first splits the directory path into individual parts using strings.Split. Then, depending on the sync direction (LocalToRemote or RemoteToLocal), it either checks and creates the directory on the remote FTP server using f.client.Mkdir or on the local machine using os.MkdirAll.
+// Depending on the sync direction (LocalToRemote or RemoteToLocal), the method either checks and creates the directory on the remote FTP server using f.mkdirAllRemote or on the local machine using os.MkdirAll.
 //
-// - For LocalToRemote sync direction, the method uses f.client.Mkdir to try creating the directory on the FTP server. If the directory already exists on the server, it assumes the operation is successful. If the directory does not exist, it returns an error.
+// - For LocalToRemote sync direction, the method uses f.mkdirAllRemote to create the directory and its parents on the FTP server. Directories that were created or found are cached in f.remoteDirCache and skipped on later calls.
 //
 // - For RemoteToLocal sync direction, the method uses os.MkdirAll to create the directory on the local machine. If the directory already exists locally, it assumes the operation is successful. If the directory does not exist, it creates all necessary parent directories recursively.
 //
 // - Returns an error if there is a problem creating the directory on either the local or remote side.
 func (f *FTP) checkOrCreateDir(dirPath string) error {
-	pathParts := strings.Split(dirPath, "/")
-	currentPath := ""
-
 	switch f.Direction {
 	case LocalToRemote:
-		for _, part := range pathParts {
-			currentPath = currentPath + "/" + part
-			// First, try to make the directory
-			_, err := f.client.Mkdir(currentPath)
-			if err != nil {
-				// If that fails, assume it's because the directory already exists and check it
-				_, err := f.client.ReadDir(currentPath)
-				if err != nil {
-					// If that also fails, return the error
-					return err
-				}
-			}
-		}
+		return f.mkdirAllRemote(dirPath)
 	case RemoteToLocal:
-		for _, part := range pathParts {
-			currentPath = filepath.Join(currentPath, part)
-			err := os.MkdirAll(currentPath, os.ModePerm)
-			if err != nil {
-				// If that fails, assume it's because the directory already exists
-				if !os.IsExist(err) {
-					// If the error is not because the directory already exists, return the error
-					return err
-				}
-			}
-		}
+		return os.MkdirAll(dirPath, os.ModePerm)
 	}
 
 	return nil
@@ -604,10 +771,15 @@ func (f *FTP) checkOrCreateDir(dirPath string) error {
 
 // Worker starts a new worker goroutine that processes tasks received from the worker pool.
 //
-// The method listens for tasks on the f.Pool.Tasks channel, which is a buffered channel used for queuing tasks. Each task contains an EventType (fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod) and a Name (the file path of the task).
+// The method receives tasks from the f.Pool.Tasks channel with f.Pool.Next, which is a buffered channel used for queuing tasks. Each task contains an EventType (fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod) and a Name (the file path of the task).
 //
 // Depending on the EventType and the sync direction (LocalToRemote or RemoteToLocal), the method performs different actions:
 //
+// - For fsnotify.Create events:
+//   - LocalToRemote: If the file was just renamed from another name in the same directory, calls f.renameRemoteFile to rename
+//     the remote file. Otherwise, or if renaming fails, calls f.uploadFile to upload the file to the remote FTP server.
+//   - RemoteToLocal: Calls f.downloadFile to download the new file from the remote FTP server to the local machine.
+//
 // - For fsnotify.Write events:
 //   - LocalToRemote: Calls f.uploadFile to upload the modified or newly created file to the remote FTP server.
 //   - RemoteToLocal: Calls f.downloadFile to download the modified or newly created file from the remote FTP server to the local machine.
@@ -616,68 +788,105 @@ func (f *FTP) checkOrCreateDir(dirPath string) error {
 //   - LocalToRemote: Calls f.removeRemoteFile to delete the specified file from the remote FTP server.
 //   - RemoteToLocal: Calls f.removeLocalFile to delete the specified file from the local machine.
 //
-// - For fsnotify.Rename events:
-//   - LocalToRemote: Calls f.uploadFile to upload the renamed file to the remote FTP server, then calls f.removeRemoteFile to delete the original file from the server.
-//   - RemoteToLocal: Calls f.downloadFile to download the renamed file from the remote FTP server to the local machine, then calls f.removeLocalFile to delete the original file from the local machine.
+// - For fsnotify.Rename events, which carry the old name of the file (the new name is reported by a separate Create event):
+//   - LocalToRemote: Calls f.renamed to wait for the Create event of the new name. If none arrives within renamePairWindow,
+//     the file under its old name is deleted from the remote FTP server.
+//   - RemoteToLocal: Calls f.removeLocalFile to delete the file under its old name from the local machine.
 //
 // - For fsnotify.Chmod events: The method logs a message indicating that the permissions of a file have changed.
 //
-// After processing each task, the method marks it as done using f.Pool.WG.Done(), which decrements the worker pool's WaitGroup counter.
+// Tasks for temporary files matching f.config.TempFilePatterns, and hidden files without f.config.SyncHidden, are ignored. Completed tasks are removed from the journal, and failed ones are queued again with their RetryCount incremented by retryTask, unless the error is permanent or the transfer already used up its retries.
+//
+// The outcome of each task is reported to the MetricsRecorder of f.Pool.
+//
+// After processing each task, the method marks it as done using f.Pool.Done(), which decrements the worker pool's WaitGroup counter and lets f.Pool.Pause proceed.
+// The method returns when the channel is closed, when f.Pool.Stop is called or, with an IdleWorkerTimeout, when no task arrives in time.
 func (f *FTP) Worker() {
-	defer f.Pool.WG.Done()
-	for task := range f.Pool.Tasks {
-		logger.Println("Processing task:", task)
+	for {
+		task, ok := f.Pool.Next()
+		if !ok {
+			return
+		}
+		if f.ignored(task.Name) {
+			logger.Debug("Ignoring temporary file:", task.Name)
+			f.taskDone(task)
+			f.Pool.Done()
+			continue
+		}
+		logger.Debug("Processing task:", task)
+		start := f.clock().Now()
+		var err error
 		switch task.EventType {
-		case fsnotify.Write:
+		case fsnotify.Create:
 			switch f.Direction {
 			case LocalToRemote:
-				err := f.uploadFile(task.Name)
+				oldPath, ok := f.takeRenamePair(task.Name)
+				if ok && oldPath != task.Name {
+					err = f.renameRemoteFile(oldPath, task.Name)
+					if err == nil {
+						break
+					}
+					logger.Warn("Error renaming remote file, uploading it instead:", err)
+					if err := f.removeRemoteFile(oldPath); err != nil {
+						logger.Errorf("Error removing remote file (attempt %d): %v", task.RetryCount+1, err)
+					}
+				}
+				err = f.uploadFile(task.Name)
 				if err != nil {
-					logger.Println("Error uploading file:", err)
+					logger.Errorf("Error uploading file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			case RemoteToLocal:
-				err := f.downloadFile(task.Name)
+				err = f.downloadFile(task.Name)
 				if err != nil {
-					logger.Println("Error downloading file:", err)
+					logger.Errorf("Error downloading file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			}
-		case fsnotify.Remove:
+		case fsnotify.Write:
 			switch f.Direction {
 			case LocalToRemote:
-				err := f.removeRemoteFile(task.Name)
+				err = f.uploadFile(task.Name)
 				if err != nil {
-					logger.Println("Error removing remote file:", err)
+					logger.Errorf("Error uploading file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			case RemoteToLocal:
-				err := f.removeLocalFile(task.Name)
+				err = f.downloadFile(task.Name)
 				if err != nil {
-					logger.Println("Error removing local file:", err)
+					logger.Errorf("Error downloading file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			}
-		case fsnotify.Rename:
+		case fsnotify.Remove:
 			switch f.Direction {
 			case LocalToRemote:
-				err := f.uploadFile(task.Name)
-				if err != nil {
-					logger.Println("Error uploading file:", err)
-				}
 				err = f.removeRemoteFile(task.Name)
 				if err != nil {
-					logger.Println("Error removing remote file:", err)
+					logger.Errorf("Error removing remote file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			case RemoteToLocal:
-				err := f.downloadFile(task.Name)
+				err = f.removeLocalFile(task.Name)
 				if err != nil {
-					logger.Println("Error downloading file:", err)
+					logger.Errorf("Error removing local file (attempt %d): %v", task.RetryCount+1, err)
 				}
+			}
+		case fsnotify.Rename:
+			// The task holds the old name, which no longer exists; the new name arrives as a Create event.
+			switch f.Direction {
+			case LocalToRemote:
+				f.renamed(task.Name)
+			case RemoteToLocal:
 				err = f.removeLocalFile(task.Name)
 				if err != nil {
-					logger.Println("Error removing local file:", err)
+					logger.Errorf("Error removing local file (attempt %d): %v", task.RetryCount+1, err)
 				}
 			}
 		case fsnotify.Chmod:
-			logger.Println("Permissions of file changed:", task.Name)
+			logger.Debug("Permissions of file changed:", task.Name)
+		}
+		if err == nil {
+			f.taskDone(task)
+		} else {
+			f.retryTask(task, err)
 		}
-		f.Pool.WG.Done()
+		f.Pool.RecordTask(f.clock(), start, err)
+		f.Pool.Done()
 	}
 }
@@ -0,0 +1,115 @@
+package ftp
+
+import (
+	"errors"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// errNoJournal is returned by ReplayJournal when JournalPath is not set.
+var errNoJournal = errors.New("ftp: no journal configured")
+
+// openJournal opens the journal at config.JournalPath, or returns nil if no path is configured.
+func openJournal(config *ExtraConfig) (*worker.Journal, error) {
+	if config == nil || config.JournalPath == "" {
+		return nil, nil
+	}
+	return worker.OpenJournal(config.JournalPath)
+}
+
+// enqueue is a method of the FTP struct that records task in the journal and queues it for the workers.
+func (f *FTP) enqueue(task worker.Task) {
+	if f.journal != nil {
+		err := f.journal.Add(task)
+		if err != nil {
+			logger.Error("Error writing journal:", err)
+		}
+	}
+	f.queued.add(task)
+	f.Pool.Submit(task)
+}
+
+// journalFailed is a method of the FTP struct that records in the journal, if any, that processing task failed,
+// see worker.Journal.Failed.
+func (f *FTP) journalFailed(task worker.Task) {
+	if f.journal == nil {
+		return
+	}
+	err := f.journal.Failed(task)
+	if err != nil {
+		logger.Error("Error writing journal:", err)
+	}
+}
+
+// retryTask is a method of the FTP struct that queues task, which failed with err, again with its RetryCount
+// incremented, until it failed MaxRetries times. Tasks failing with an error f.retryable finds permanent, or whose
+// transfer already made MaxRetries attempts in store or retrieve, are not queued again. A task that is not queued
+// again is dropped and left in the journal, if any, with its failed attempt recorded.
+func (f *FTP) retryTask(task worker.Task, err error) {
+	f.journalFailed(task)
+	var exhausted *exhaustedError
+	switch {
+	case errors.As(err, &exhausted):
+		logger.Errorf("Giving up on %s: %v", task.Name, err)
+	case !f.retryable(err):
+		logger.Errorf("Giving up on %s after a permanent error: %v", task.Name, err)
+	case task.RetryCount+1 >= f.config.MaxRetries:
+		logger.Errorf("Giving up on %s after %d failed attempts", task.Name, task.RetryCount+1)
+	default:
+		f.Pool.Retry(task)
+		return
+	}
+	f.queued.done(task)
+}
+
+// taskDone is a method of the FTP struct that removes the completed task from the journal, if any, and stops
+// counting it as queued.
+func (f *FTP) taskDone(task worker.Task) {
+	f.queued.done(task)
+	if f.journal == nil {
+		return
+	}
+	err := f.journal.Done(task)
+	if err != nil {
+		logger.Error("Error writing journal:", err)
+	}
+}
+
+// ReplayJournal is a method of the FTP struct that queues the tasks recorded in the journal but never completed,
+// e.g. because the connection was lost or the process stopped while they were pending.
+//
+// Tasks that already failed MaxRetries times are not queued again; they stay in the journal for inspection.
+// The journal is also replayed after every successful Reconnect, skipping the tasks still queued or being processed. The workers must be running,
+// e.g. through WatchDirectory, for the replayed tasks to be processed.
+//
+// - Returns an error if JournalPath is not set.
+func (f *FTP) ReplayJournal() error {
+	return f.replayJournal(false)
+}
+
+// replayJournal is a method of the FTP struct that queues the pending tasks of the journal, see ReplayJournal.
+//
+// - skipQueued skips the tasks still queued or being processed, so replaying after a Reconnect does not transfer
+// them twice. Tasks dropped from the pool by Stop are still counted as queued, so ReplayJournal replays them all.
+func (f *FTP) replayJournal(skipQueued bool) error {
+	if f.journal == nil {
+		return errNoJournal
+	}
+	var pending []worker.Task
+	for _, task := range f.journal.Pending() {
+		if !skipQueued || !f.queued.has(task) {
+			pending = append(pending, task)
+		}
+	}
+	if len(pending) > 0 {
+		logger.Infof("Replaying %d pending tasks from the journal", len(pending))
+	}
+	for _, task := range pending {
+		if task.RetryCount >= f.config.MaxRetries {
+			logger.Errorf("Giving up on %s after %d failed attempts", task.Name, task.RetryCount)
+			continue
+		}
+		f.enqueue(task)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package ftp
+
+import (
+	"os"
+	"strings"
+
+	"github.com/secsy/goftp"
+)
+
+// ListingMode is the command the FTP server lists directories with, negotiated from its FEAT reply.
+type ListingMode int
+
+const (
+	//ListingLIST parses LIST replies, whose timestamps have at best minute precision and are in the server's time zone
+	//(see ExtraConfig.ServerLocation)
+	ListingLIST ListingMode = iota
+	//ListingMLSD uses the MLSD and MLST commands of RFC 3659, whose timestamps are in UTC with second or sub-second precision
+	ListingMLSD
+)
+
+// String returns the name of the listing command.
+func (m ListingMode) String() string {
+	if m == ListingMLSD {
+		return "MLSD"
+	}
+	return "LIST"
+}
+
+// ListingMode is a method of the FTP struct that returns how the server lists directories. goftp lists with MLSD
+// and falls back to LIST for servers that reject it; the mode tells which of the two the server supports.
+//
+// The mode is negotiated with a FEAT command on the first call, and again after Reconnect. If the server cannot be
+// reached, the method returns ListingLIST, the conservative choice for change detection, and negotiates on the next call.
+func (f *FTP) ListingMode() ListingMode {
+	client := f.ftpClient()
+	f.listingMu.Lock()
+	defer f.listingMu.Unlock()
+	if f.listingClient == client {
+		return f.listing
+	}
+	mode, err := negotiateListing(client)
+	if err != nil {
+		logger.Warn("Failed to negotiate the listing mode:", err)
+		return ListingLIST
+	}
+	logger.Debug("Listing remote directories with", mode)
+	f.listing = mode
+	f.listingClient = client
+	return mode
+}
+
+// negotiateListing sends FEAT on a new connection of client and returns ListingMLSD if the server advertises MLST,
+// which per RFC 3659 also covers MLSD. Servers that do not support FEAT list with LIST.
+func negotiateListing(client *goftp.Client) (ListingMode, error) {
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		return ListingLIST, err
+	}
+	defer conn.Close()
+	code, msg, err := conn.SendCommand("FEAT")
+	if err != nil {
+		return ListingLIST, err
+	}
+	if code != 211 {
+		return ListingLIST, nil
+	}
+	for _, line := range strings.Split(msg, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "MLST") {
+			return ListingMLSD, nil
+		}
+	}
+	return ListingLIST, nil
+}
+
+// remoteChanged reports whether a remote file changed between two snapshots of the remote poller.
+// LIST timestamps have at best minute precision, so with ListingLIST a change of size counts as well.
+func remoteChanged(prev, cur os.FileInfo, mode ListingMode) bool {
+	if prev.ModTime().Before(cur.ModTime()) {
+		return true
+	}
+	return mode == ListingLIST && prev.Size() != cur.Size()
+}
@@ -0,0 +1,59 @@
+package ftp
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// listPermissions matches the permissions column of a LIST line, e.g. "-rwxr-xr-x".
+var listPermissions = regexp.MustCompile(`^\s*[-dlbcps][-r][-w][-xsS][-r][-w][-xsS][-r][-w][-xtT]`)
+
+// remoteMode is a function that returns the permission bits of a remote file, if the server reported them: LIST
+// lines always carry them, MLSD and MLST entries only with the UNIX.mode fact. goftp reports read-only permissions
+// for the other entries, which do not describe the file.
+//
+// - info is the FileInfo returned by goftp, or nil.
+//
+// - Returns the permission bits and true, or false if the server did not report them.
+func remoteMode(info os.FileInfo) (os.FileMode, bool) {
+	if info == nil {
+		return 0, false
+	}
+	raw, _ := info.Sys().(string)
+	if !listPermissions.MatchString(raw) && !strings.Contains(strings.ToLower(raw), "unix.mode=") {
+		return 0, false
+	}
+	return info.Mode().Perm(), true
+}
+
+// applyRemoteMode is a method of the FTP struct that gives a downloaded file the permissions of the remote file when
+// PreserveRemoteMode is set, or DefaultFileMode, if set, when the server does not report them. Errors are logged.
+//
+// - file is the downloaded local file.
+//
+// - remotePath is the path of the file on the FTP server, stat'ed when info is nil.
+//
+// - info is the FileInfo of the remote file from its directory listing, or nil.
+func (f *FTP) applyRemoteMode(file *os.File, remotePath string, info os.FileInfo) {
+	if !f.config.PreserveRemoteMode {
+		return
+	}
+	if info == nil {
+		var err error
+		info, err = f.ftpClient().Stat(remotePath)
+		if err != nil {
+			logger.Debug("Cannot stat remote file for its mode:", err)
+		}
+	}
+	mode, ok := remoteMode(info)
+	if !ok {
+		if f.config.DefaultFileMode == 0 {
+			return
+		}
+		mode = f.config.DefaultFileMode
+	}
+	if err := file.Chmod(mode); err != nil {
+		logger.Error("Error setting file mode:", err)
+	}
+}
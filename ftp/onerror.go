@@ -0,0 +1,51 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorPolicy selects what the initial sync does when a file or directory cannot be synced.
+type ErrorPolicy int
+
+const (
+	//OnErrorAbort stops the sync at the first error and returns it
+	OnErrorAbort ErrorPolicy = iota
+	//OnErrorContinue logs each error and goes on with the next entry
+	OnErrorContinue
+	//OnErrorCollect goes on with the next entry and returns all the errors, joined with errors.Join, at the end
+	OnErrorCollect
+)
+
+// abortSync is a method of the FTP struct that applies the OnError policy to an entry syncDir failed to sync.
+//
+// - path is the source path of the entry, for the messages.
+//
+// - err is the error syncing the entry.
+//
+// - Returns true if syncDir must stop and return err.
+func (f *FTP) abortSync(path string, err error) bool {
+	if f.config.OnError == OnErrorAbort {
+		return true
+	}
+	if f.config.OnError == OnErrorContinue {
+		logger.Errorf("Error syncing %s: %v", path, err)
+	}
+	f.syncErrs = append(f.syncErrs, fmt.Errorf("%s: %w", path, err))
+	return false
+}
+
+// syncErrors is a method of the FTP struct that returns the error the initial sync ends with for the entries
+// abortSync let it skip.
+//
+// - Returns the skipped errors joined with OnErrorCollect, otherwise nil.
+func (f *FTP) syncErrors() error {
+	if len(f.syncErrs) == 0 {
+		return nil
+	}
+	if f.config.OnError == OnErrorCollect {
+		return errors.Join(f.syncErrs...)
+	}
+	logger.Warnf("Initial sync skipped %d entries after errors", len(f.syncErrs))
+	return nil
+}
@@ -0,0 +1,14 @@
+package ftp
+
+// Pause is a method of the FTP struct that stops the workers from starting new transfers, e.g. during a maintenance
+// window, once the transfers in progress are done.
+//
+// The watcher keeps running and the changes it reports are queued until Resume is called.
+func (f *FTP) Pause() {
+	f.Pool.Pause()
+}
+
+// Resume is a method of the FTP struct that lets the workers process the queued changes again after Pause.
+func (f *FTP) Resume() {
+	f.Pool.Resume()
+}
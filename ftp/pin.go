@@ -0,0 +1,70 @@
+package ftp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrCertPinMismatch is returned when the certificate presented by the FTPS server does not match
+// ExtraConfig.PinnedCertSHA256.
+var ErrCertPinMismatch = errors.New("ftp: server certificate does not match the pinned SHA-256 fingerprint")
+
+// certPin checks the leaf certificate of the FTPS server against a SHA-256 fingerprint.
+type certPin struct {
+	//fingerprint is the expected fingerprint, as lowercase hex without separators
+	fingerprint string
+	//mu guards mismatch
+	mu sync.Mutex
+	//mismatch is the error of the last handshake that failed the pin, kept because goftp does not wrap it
+	mismatch error
+}
+
+// normalizeFingerprint is a function that returns fingerprint as lowercase hex without the colons
+// that tools such as openssl print between bytes.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+// tlsConfig is a method of the certPin struct that returns the TLS configuration for the FTPS connections.
+// The pin replaces the verification against the system CA pool, so self-signed certificates can be pinned.
+func (p *certPin) tlsConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: p.verify,
+	}
+}
+
+// verify is a method of the certPin struct, used as tls.Config.VerifyPeerCertificate, that fails the handshake
+// with ErrCertPinMismatch unless the leaf certificate matches the fingerprint.
+func (p *certPin) verify(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return p.fail(fmt.Errorf("%w: no certificate presented", ErrCertPinMismatch))
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	got := hex.EncodeToString(sum[:])
+	if got != p.fingerprint {
+		return p.fail(fmt.Errorf("%w: got %s", ErrCertPinMismatch, got))
+	}
+	return nil
+}
+
+// fail is a method of the certPin struct that records err as the last mismatch and returns it.
+func (p *certPin) fail(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mismatch = err
+	return err
+}
+
+// lastMismatch is a method of the certPin struct that returns the error of the last handshake that failed the pin, or nil.
+func (p *certPin) lastMismatch() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mismatch
+}
@@ -0,0 +1,35 @@
+package ftp
+
+import "context"
+
+// PingRemote is a method of the FTP struct that checks that the server is reachable and the credentials are still
+// accepted, e.g. before starting WatchDirectory or in a health-check loop. Unlike an ICMP ping, it goes through the
+// control connection, the login and, for listings, a data connection.
+//
+// - ctx is the context of the check. PingRemote returns ctx.Err() if it is done before the server answers.
+//
+// The method states f.config.RemoteDir with f.client.Stat on servers supporting MLST. goftp falls back to LIST for
+// Stat, which cannot describe a directory, so other servers have the directory listed instead.
+//
+// - Returns an error if the server cannot be reached or f.config.RemoteDir cannot be stated.
+func (f *FTP) PingRemote(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if f.ListingMode() == ListingMLSD {
+			_, err = f.ftpClient().Stat(f.config.RemoteDir)
+		} else {
+			_, err = f.ftpClient().ReadDir(f.config.RemoteDir)
+		}
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
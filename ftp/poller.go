@@ -0,0 +1,178 @@
+package ftp
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchBackend selects how local changes are detected in LocalToRemote mode.
+type WatchBackend int
+
+const (
+	//WatchAuto uses fsnotify and falls back to polling when fsnotify fails or never delivers events
+	WatchAuto WatchBackend = iota
+	//WatchFSNotify always uses fsnotify
+	WatchFSNotify
+	//WatchPolling walks LocalDir every PollInterval and diffs successive snapshots
+	WatchPolling
+)
+
+// defaultPollInterval is used when ExtraConfig.PollInterval is not set.
+const defaultPollInterval = time.Second
+
+// probeFileName is the name of the file WatchAuto creates in LocalDir to check that fsnotify delivers events.
+const probeFileName = ".syncpkg-probe"
+
+// probeTimeout is how long WatchAuto waits for fsnotify to report the probe file.
+var probeTimeout = 2 * time.Second
+
+// fsnotifyDelivers reports whether fsnotify delivers events for dir. It creates a probe file in dir
+// and waits for the event dispatcher to signal on seen that the file was reported.
+//
+// It is a variable so tests can simulate file systems on which fsnotify stays silent.
+var fsnotifyDelivers = func(dir string, seen <-chan struct{}) bool {
+	probe := filepath.Join(dir, probeFileName)
+	file, err := os.Create(probe)
+	if err != nil {
+		return false
+	}
+	_ = file.Close()
+	defer func() {
+		_ = os.Remove(probe)
+	}()
+
+	select {
+	case <-seen:
+		return true
+	case <-time.After(probeTimeout):
+		return false
+	}
+}
+
+// pollInterval is a method of the FTP struct that returns the configured PollInterval, or defaultPollInterval when unset.
+func (f *FTP) pollInterval() time.Duration {
+	if f.config.PollInterval > 0 {
+		return f.config.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// clock is a method of the FTP struct that returns the configured Clock, or worker.RealClock when unset.
+func (f *FTP) clock() worker.Clock {
+	return worker.ClockOrReal(f.config.Clock)
+}
+
+// canPoll is a method of the FTP struct that reports whether local changes may be detected by polling instead of fsnotify.
+func (f *FTP) canPoll() bool {
+	return f.Direction == LocalToRemote && f.config.WatchBackend != WatchFSNotify
+}
+
+// watchLocal is a method of the FTP struct that starts watching the local directory with the configured WatchBackend.
+//
+// - watcher is the fsnotify watcher, or nil if it could not be created.
+//
+// - watcherErr is the error returned when creating the watcher, if any.
+//
+// - seen is signalled by the event dispatcher when the probe file is reported.
+//
+// With WatchAuto the method adds the directories to the fsnotify watcher and checks that a probe file is reported.
+// If the watcher could not be set up or the probe is never reported, it closes the watcher and polls instead.
+// When polling, the method blocks until the context (f.ctx) is canceled.
+//
+// - Returns an error if the directories cannot be watched or polled.
+func (f *FTP) watchLocal(watcher *fsnotify.Watcher, watcherErr error, seen <-chan struct{}) error {
+	switch f.config.WatchBackend {
+	case WatchPolling:
+		return f.pollLocalDir(f.config.LocalDir)
+	case WatchFSNotify:
+		if watcherErr != nil {
+			return watcherErr
+		}
+		return f.AddDirectoriesToWatcher(watcher, f.config.LocalDir)
+	}
+
+	if watcherErr == nil {
+		watcherErr = f.AddDirectoriesToWatcher(watcher, f.config.LocalDir)
+	}
+	if watcherErr == nil && fsnotifyDelivers(f.config.LocalDir, seen) {
+		return nil
+	}
+	logger.Warn("fsnotify is not delivering events, falling back to polling:", watcherErr)
+	if watcher != nil {
+		_ = watcher.Close()
+	}
+	return f.pollLocalDir(f.config.LocalDir)
+}
+
+// pollLocalDir is a method of the FTP struct that walks the local directory every PollInterval and compares
+// the snapshot with the previous one, the same way AddDirectoriesToWatcher polls the remote directory.
+//
+// - rootDir is the local directory to poll.
+//
+// New or modified files are enqueued as fsnotify.Write tasks and removed files as fsnotify.Remove tasks.
+// The method keeps polling until the context (f.ctx) is canceled.
+//
+// - Returns an error if the local directory cannot be walked.
+func (f *FTP) pollLocalDir(rootDir string) error {
+	var prevFiles map[string]os.FileInfo
+	for {
+		newFiles := make(map[string]os.FileInfo)
+		err := walkLocalDir(rootDir, newFiles)
+		if err != nil {
+			return err
+		}
+		// Check for new, modified or removed files.
+		if prevFiles != nil {
+			for p, file := range newFiles {
+				if f.extensionFiltered(p) {
+					continue
+				}
+				prevFile, exists := prevFiles[p]
+				if !exists || prevFile.ModTime().Before(file.ModTime()) || prevFile.Size() != file.Size() {
+					f.enqueue(worker.Task{EventType: fsnotify.Write, Name: p})
+				}
+			}
+			for p := range prevFiles {
+				_, exists := newFiles[p]
+				if !exists && !f.extensionFiltered(p) {
+					f.enqueue(worker.Task{EventType: fsnotify.Remove, Name: p})
+					logger.Debug("File removed:", p)
+				}
+			}
+		}
+		prevFiles = newFiles
+
+		select {
+		case <-f.ctx.Done():
+			return nil
+		case <-f.clock().After(f.pollInterval()):
+		}
+	}
+}
+
+// walkVisited is called by walkLocalDir for each path it visits, before looking at it.
+//
+// It is a variable so tests can change the tree while it is walked.
+var walkVisited = func(path string) {}
+
+// walkLocalDir traverses a local directory and its subdirectories and adds all regular files it finds to the provided map.
+// Files and directories removed while the walk lists their parent directory are skipped, not reported as errors.
+func walkLocalDir(dir string, files map[string]os.FileInfo) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		walkVisited(path)
+		if err != nil {
+			if os.IsNotExist(err) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files[path] = info
+		}
+		return nil
+	})
+}
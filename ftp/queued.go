@@ -0,0 +1,50 @@
+package ftp
+
+import (
+	"sync"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// queuedTasks counts the tasks queued by enqueue that no worker finished yet, including those queued again by
+// retryTask, so replaying the journal after a reconnect does not queue them a second time.
+type queuedTasks struct {
+	mu     sync.Mutex
+	counts map[queuedKey]int
+}
+
+// queuedKey identifies the tasks counted by queuedTasks: the same event on the same file, whatever its priority.
+type queuedKey struct {
+	eventType fsnotify.Op
+	name      string
+}
+
+// add counts task as queued.
+func (q *queuedTasks) add(task worker.Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.counts == nil {
+		q.counts = make(map[queuedKey]int)
+	}
+	q.counts[queuedKey{task.EventType, task.Name}]++
+}
+
+// done stops counting task, which a worker completed or gave up on.
+func (q *queuedTasks) done(task worker.Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := queuedKey{task.EventType, task.Name}
+	if q.counts[key] <= 1 {
+		delete(q.counts, key)
+		return
+	}
+	q.counts[key]--
+}
+
+// has reports whether task is queued or being processed.
+func (q *queuedTasks) has(task worker.Task) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.counts[queuedKey{task.EventType, task.Name}] > 0
+}
@@ -0,0 +1,44 @@
+package ftp
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// connectionRateMu guards connectionRateLimiter
+	connectionRateMu sync.RWMutex
+	// connectionRateLimiter limits the connections opened by Connect and Reconnect across all FTP instances
+	// (nil means unlimited)
+	connectionRateLimiter *rate.Limiter
+)
+
+// SetGlobalConnectionRate is a function that limits how many connections Connect and Reconnect open per second,
+// across all FTP instances of the process, so many instances or a reconnect storm do not flood a shared server.
+//
+// - rps is the number of connections per second; a connection may be opened every 1/rps seconds.
+// Zero or a negative value removes the limit, which is the default.
+func SetGlobalConnectionRate(rps float64) {
+	connectionRateMu.Lock()
+	defer connectionRateMu.Unlock()
+	if rps <= 0 {
+		connectionRateLimiter = nil
+		return
+	}
+	connectionRateLimiter = rate.NewLimiter(rate.Limit(rps), 1)
+}
+
+// waitConnectionRate is a function that blocks until the global connection rate allows a new connection.
+//
+// - Returns ctx.Err() if ctx is done first.
+func waitConnectionRate(ctx context.Context) error {
+	connectionRateMu.RLock()
+	limiter := connectionRateLimiter
+	connectionRateMu.RUnlock()
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,168 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/secsy/goftp"
+)
+
+// defaultReconnectBackoff is used when ExtraConfig.ReconnectBackoff is not set.
+const defaultReconnectBackoff = time.Second
+
+// dial creates a goftp client for the FTP server at address using the credentials in config,
+// once SetGlobalConnectionRate allows it or ctx is done.
+//
+// With PinnedCertSHA256 the client uses explicit FTPS, and a connection is opened right away so a server
+// whose certificate does not match fails with ErrCertPinMismatch here rather than on the first transfer.
+func dial(ctx context.Context, address string, config *ExtraConfig) (*goftp.Client, error) {
+	if err := waitConnectionRate(ctx); err != nil {
+		return nil, err
+	}
+	ftpConfig := goftp.Config{
+		User:           config.Username,
+		Password:       config.Password,
+		ServerLocation: config.ServerLocation,
+	}
+	if config.PinnedCertSHA256 == "" {
+		return goftp.DialConfig(ftpConfig, address)
+	}
+
+	pin := &certPin{fingerprint: normalizeFingerprint(config.PinnedCertSHA256)}
+	ftpConfig.TLSConfig = pin.tlsConfig()
+	ftpConfig.TLSMode = goftp.TLSExplicit
+	client, err := goftp.DialConfig(ftpConfig, address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		_ = client.Close()
+		if mismatch := pin.lastMismatch(); mismatch != nil {
+			return nil, mismatch
+		}
+		return nil, err
+	}
+	_ = conn.Close()
+	return client, nil
+}
+
+// ftpClient is a method of the FTP struct that returns the current goftp client.
+// The client is replaced by Reconnect, so it must not be cached across operations.
+func (f *FTP) ftpClient() *goftp.Client {
+	f.clientMu.RLock()
+	defer f.clientMu.RUnlock()
+	return f.client
+}
+
+// Reconnect is a method of the FTP struct that re-establishes the connection to the FTP server using the original
+// address and the credentials in the ExtraConfig, e.g. after the server closed the control connection (421) or restarted.
+//
+// - ctx is the context of the call. Reconnect returns ctx.Err() if it is done before the new connection is established.
+//
+// The method dials the server, checks that the new connection is usable and only then replaces the client,
+// closing the previous one. Operations already running on the previous client fail and may be retried.
+// If a journal is configured, its pending tasks that are not still queued or being processed are then replayed.
+//
+// - Returns an error if the server cannot be reached or rejects the credentials.
+func (f *FTP) Reconnect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client, err := dial(ctx, f.address, f.config)
+	if err != nil {
+		return err
+	}
+
+	// goftp connects lazily, so issue a command to make sure the connection works.
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Getwd()
+		errCh <- err
+	}()
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	f.clientMu.Lock()
+	previous := f.client
+	f.client = client
+	f.clientMu.Unlock()
+	if previous != nil {
+		_ = previous.Close()
+	}
+	logger.Info("Reconnected to FTP server.")
+
+	// Replay what may have failed while disconnected, leaving out the tasks still in the pool. Reconnect may run
+	// on a worker, so the tasks are queued from another goroutine to avoid blocking on a full queue.
+	if f.journal != nil {
+		go func() {
+			_ = f.replayJournal(true)
+		}()
+	}
+	return nil
+}
+
+// reconnectIfLost is a method of the FTP struct that reconnects to the FTP server when err shows that the connection of client was lost.
+//
+// - client is the client the failed operation ran on. If it has already been replaced by another worker, no new connection is made.
+//
+// - err is the error returned by the failed operation.
+//
+// The method makes up to f.config.MaxReconnectAttempts attempts, waiting f.config.ReconnectBackoff before the first one and
+// doubling the wait after every failed attempt. Reconnects of concurrent workers are serialized.
+//
+// - Returns true if the connection is usable again.
+func (f *FTP) reconnectIfLost(client *goftp.Client, err error) bool {
+	if f.config.MaxReconnectAttempts <= 0 || !connectionLost(err) {
+		return false
+	}
+
+	f.reconnectMu.Lock()
+	defer f.reconnectMu.Unlock()
+	if f.ftpClient() != client {
+		return true
+	}
+
+	backoff := f.config.ReconnectBackoff
+	if backoff <= 0 {
+		backoff = defaultReconnectBackoff
+	}
+	for attempt := 1; attempt <= f.config.MaxReconnectAttempts; attempt++ {
+		select {
+		case <-f.ctx.Done():
+			return false
+		case <-f.clock().After(backoff):
+		}
+		err = f.Reconnect(f.ctx)
+		if err == nil {
+			return true
+		}
+		logger.Warnf("Reconnect attempt %d/%d failed: %v", attempt, f.config.MaxReconnectAttempts, err)
+		backoff *= 2
+	}
+	return false
+}
+
+// connectionLost reports whether err shows that the connection to the FTP server was lost, rather than the server
+// rejecting the operation: a 421 (service not available) reply, or a network error that did not come from a server reply.
+func connectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) {
+		return ftpErr.Code() == 421 || ftpErr.Code() == 0
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
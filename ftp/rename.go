@@ -0,0 +1,97 @@
+package ftp
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// renamePairWindow is how long the old name of a renamed file waits for the Create event of its new name.
+// Once it expires, the file is considered moved out of the watched tree and is removed from the remote.
+const renamePairWindow = 500 * time.Millisecond
+
+// renamed is a method of the FTP struct that records oldPath, the name carried by a Rename event, so the Create event
+// of the new name can rename the remote file instead of uploading it again. If no Create event claims it within
+// renamePairWindow, the remote file is removed.
+//
+// - oldPath is the local path the file was renamed from.
+func (f *FTP) renamed(oldPath string) {
+	at := f.clock().Now()
+	f.Lock()
+	if f.renamePending == nil {
+		f.renamePending = make(map[string]time.Time)
+	}
+	f.renamePending[oldPath] = at
+	f.Unlock()
+
+	expired := f.clock().After(renamePairWindow)
+	go func() {
+		<-expired
+		f.Lock()
+		pending, ok := f.renamePending[oldPath]
+		if !ok || !pending.Equal(at) {
+			// Claimed by a Create event, or renamed again since.
+			f.Unlock()
+			return
+		}
+		delete(f.renamePending, oldPath)
+		f.Unlock()
+		if err := f.removeRemoteFile(oldPath); err != nil {
+			logger.Error("Error removing remote file:", err)
+		}
+	}()
+}
+
+// takeRenamePair is a method of the FTP struct that returns the pending old name paired with newPath, the name carried
+// by a Create event, and forgets it. The pair is newPath itself if it was renamed away and recreated, or else the most
+// recent old name in the same directory that has not expired.
+//
+// - newPath is the local path of the created file.
+//
+// - Returns the old path and true if one was pending, or false otherwise.
+func (f *FTP) takeRenamePair(newPath string) (string, bool) {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.renamePending[newPath]; ok {
+		delete(f.renamePending, newPath)
+		return newPath, true
+	}
+	var oldPath string
+	var newest time.Time
+	dir := filepath.Dir(newPath)
+	for path, at := range f.renamePending {
+		if filepath.Dir(path) != dir || f.clock().Now().Sub(at) > renamePairWindow {
+			continue
+		}
+		if oldPath == "" || at.After(newest) {
+			oldPath, newest = path, at
+		}
+	}
+	if oldPath == "" {
+		return "", false
+	}
+	delete(f.renamePending, oldPath)
+	return oldPath, true
+}
+
+// renameRemoteFile is a method of the FTP struct that renames the remote counterpart of oldPath to that of newPath.
+//
+// - oldPath is the local path the file was renamed from.
+//
+// - newPath is the local path the file was renamed to.
+//
+// - Returns an error if the FTP server fails to rename the file.
+func (f *FTP) renameRemoteFile(oldPath, newPath string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	oldRemote := strings.Replace(oldPath, f.config.LocalDir, f.config.RemoteDir, 1)
+	newRemote := strings.Replace(newPath, f.config.LocalDir, f.config.RemoteDir, 1)
+	err := f.ftpClient().Rename(oldRemote, newRemote)
+	if err != nil {
+		return err
+	}
+	// The path may have been a directory, so forget it and everything below it
+	f.invalidateDirCache(oldRemote)
+	return nil
+}
@@ -0,0 +1,64 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/secsy/goftp"
+)
+
+// IsTransient is a function that reports whether a failed transfer may succeed if it is attempted again. It is the
+// default ExtraConfig.RetryClassifier.
+//
+// - err is the error of the failed attempt.
+//
+// Replies of the server are classified by their code: 4xx replies, such as 421 (service not available) or 451 (local
+// error), are transient and 5xx replies, such as 550 (permission denied or no such file), are permanent. Local
+// permission and missing-file errors and canceled contexts are permanent as well. Other errors, such as timeouts,
+// EOF and reset connections, are transient.
+//
+// - Returns true if the transfer should be retried.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code() != 0 {
+		return ftpErr.Code() < 500
+	}
+	var replyErr *replyError
+	if errors.As(err, &replyErr) {
+		return replyErr.code < 500
+	}
+	return true
+}
+
+// exhaustedError is the error of a transfer that was attempted f.config.MaxRetries times, wrapping the error of the
+// last attempt. The workers do not queue the task of such a transfer again, since it already used up its retries.
+type exhaustedError struct {
+	op       string // op is what failed, such as "upload file".
+	attempts int
+	err      error
+}
+
+func (e *exhaustedError) Error() string {
+	return fmt.Sprintf("failed to %s after %d attempts: %v", e.op, e.attempts, e.err)
+}
+
+func (e *exhaustedError) Unwrap() error {
+	return e.err
+}
+
+// retryable is a method of the FTP struct that reports whether a failed attempt is retried, according to
+// f.config.RetryClassifier or IsTransient.
+func (f *FTP) retryable(err error) bool {
+	if f.config.RetryClassifier != nil {
+		return f.config.RetryClassifier(err)
+	}
+	return IsTransient(err)
+}
@@ -0,0 +1,62 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrSpecialFile is returned by a sync that finds a FIFO, socket or device file while SkipSpecialFiles is not set.
+// Such files cannot be represented on the FTP server, and opening a FIFO blocks until a writer opens it.
+var ErrSpecialFile = errors.New("ftp: not a regular file")
+
+// isSpecialMode is a function that reports whether mode is that of a FIFO, socket, device or other irregular file.
+func isSpecialMode(mode os.FileMode) bool {
+	return mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice|os.ModeIrregular) != 0
+}
+
+// skipSpecial is a method of the FTP struct that reports whether the local file at path must not be uploaded because
+// it is a special file. It is skipped with a notice if f.config.SkipSpecialFiles is set, and reported with
+// ErrSpecialFile otherwise.
+//
+// - path is the path of the local file.
+//
+// - mode is the mode of the file, as returned by os.Lstat. The mode of a symlink is that of its target.
+//
+// - Returns true if the file must not be uploaded, and ErrSpecialFile if SkipSpecialFiles is not set.
+func (f *FTP) skipSpecial(path string, mode os.FileMode) (bool, error) {
+	if mode&os.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, nil
+		}
+		mode = info.Mode()
+	}
+	if !isSpecialMode(mode) {
+		return false, nil
+	}
+	if !f.config.SkipSpecialFiles {
+		return true, fmt.Errorf("%w: %s", ErrSpecialFile, path)
+	}
+	logger.Info("Skipping special file:", path)
+	return true, nil
+}
+
+// specialEvent is a method of the FTP struct that reports whether a Create or Write event of the fsnotify watcher is
+// for a special file, whose event is dropped since the file cannot be uploaded.
+func (f *FTP) specialEvent(event fsnotify.Event) bool {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return false
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return false
+	}
+	skip, err := f.skipSpecial(event.Name, info.Mode())
+	if err != nil {
+		logger.Error("Ignoring event:", err)
+	}
+	return skip
+}
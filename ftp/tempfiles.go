@@ -0,0 +1,30 @@
+package ftp
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultTempFilePatterns is used when ExtraConfig.TempFilePatterns is nil.
+var defaultTempFilePatterns = []string{".swp", ".swpx", "~", ".tmp", ".part"}
+
+// isTempFile reports whether the file at path matches one of the TempFilePatterns and must not be synced.
+// Patterns containing glob characters (*, ? or [) are matched against the base name with filepath.Match;
+// any other pattern matches names ending with it.
+func (f *FTP) isTempFile(path string) bool {
+	patterns := f.config.TempFilePatterns
+	if patterns == nil {
+		patterns = defaultTempFilePatterns
+	}
+	name := filepath.Base(path)
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		} else if strings.HasSuffix(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
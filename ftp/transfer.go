@@ -0,0 +1,169 @@
+package ftp
+
+import (
+	"context"
+	"os"
+)
+
+// Upload is a method of the FTP struct that uploads a single local file to the FTP server.
+//
+// - ctx is the context of the call. No further attempt is made once it is done.
+//
+// - localPath is the path of the local file, used as is.
+//
+// - remotePath is the path the file is stored at on the FTP server, used as is rather than relative to f.config.RemoteDir.
+//
+// The upload is retried up to f.config.MaxRetries times, like the uploads of the sync, unless RetryClassifier finds the
+// error permanent, and honors ChunkSize, AutoASCII and AppendGrowingFiles.
+//
+// - Returns an error if ctx is done, the upload fails with a permanent error or after the maximum number of retries.
+func (f *FTP) Upload(ctx context.Context, localPath, remotePath string) error {
+	return f.store(ctx, localPath, remotePath)
+}
+
+// Download is a method of the FTP struct that downloads a single file from the FTP server.
+//
+// - ctx is the context of the call. No further attempt is made once it is done.
+//
+// - remotePath is the path of the file on the FTP server, used as is rather than relative to f.config.RemoteDir.
+//
+// - localPath is the path the file is written to, created or truncated as needed.
+//
+// The download is retried up to f.config.MaxRetries times, like the downloads of the sync, unless RetryClassifier finds
+// the error permanent.
+//
+// - Returns an error if ctx is done, the download fails with a permanent error or after the maximum number of retries.
+func (f *FTP) Download(ctx context.Context, remotePath, localPath string) error {
+	f.Lock()
+	defer f.Unlock()
+	return f.retrieve(ctx, remotePath, localPath)
+}
+
+// store is a method of the FTP struct that uploads localPath to remotePath, retrying up to f.config.MaxRetries times
+// the errors f.retryable finds transient.
+func (f *FTP) store(ctx context.Context, localPath, remotePath string) error {
+	// Open the file for reading
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	// Send only the new tail of files that grew
+	if f.config.AppendGrowingFiles && !(f.config.AutoASCII && f.isText(file)) {
+		appended, err := f.appendTail(file, remotePath)
+		if err != nil {
+			logger.Warn("Error appending to the remote file, uploading it whole:", err)
+		} else if appended {
+			logger.Infof("Uploaded file: %s", localPath)
+			return nil
+		}
+	}
+
+	// Upload large files in chunks
+	if f.config.ChunkSize > 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() > f.config.ChunkSize {
+			err = f.uploadChunked(file, info.Size(), remotePath)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Uploaded file: %s", localPath)
+			return nil
+		}
+	}
+
+	ascii := f.config.AutoASCII && f.isText(file)
+
+	// Try to upload the file for MaxRetries times
+	for i := 0; i < f.config.MaxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Reset the file pointer to the beginning of the file
+		_, err = file.Seek(0, 0)
+		if err != nil {
+			return err
+		}
+
+		// Upload the file to the FTP server
+		client := f.ftpClient()
+		if ascii {
+			err = f.storeASCII(remotePath, file)
+		} else {
+			err = client.Store(remotePath, file)
+		}
+		if err != nil {
+			// If upload fails, log the error, reconnect if the connection was lost and try again
+			logger.Warnf("Attempt %d/%d: Error uploading file: %v", i+1, f.config.MaxRetries, err)
+			if !f.retryable(err) {
+				return err
+			}
+			f.reconnectIfLost(client, err)
+			continue
+		} else {
+			// If upload succeeds, log the success and return nil
+			logger.Infof("Uploaded file: %s", localPath)
+			return nil
+		}
+	}
+
+	// If we reach this point, all attempts to upload the file have failed
+	return &exhaustedError{op: "upload file", attempts: f.config.MaxRetries, err: err}
+}
+
+// retrieve is a method of the FTP struct that downloads remotePath to localPath, retrying up to f.config.MaxRetries times
+// the errors f.retryable finds transient.
+// The caller holds the FTP lock.
+func (f *FTP) retrieve(ctx context.Context, remotePath, localPath string) error {
+	// Create the local file
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	for i := 0; i < f.config.MaxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Discard what a failed attempt wrote
+		if i > 0 {
+			_, err = file.Seek(0, 0)
+			if err == nil {
+				err = file.Truncate(0)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// Download the file from the FTP server
+		client := f.ftpClient()
+		err = client.Retrieve(remotePath, file)
+		if err != nil {
+			// If download fails, log the error, reconnect if the connection was lost and try again
+			logger.Warnf("Attempt %d/%d: Error downloading file: %v", i+1, f.config.MaxRetries, err)
+			if !f.retryable(err) {
+				return err
+			}
+			f.reconnectIfLost(client, err)
+			continue
+		} else {
+			// If download succeeds, log the success and return nil
+			f.applyRemoteMode(file, remotePath, nil)
+			logger.Infof("Downloaded file: %s", localPath)
+			return nil
+		}
+	}
+
+	// If we reach this point, all attempts to download the file have failed
+	return &exhaustedError{op: "download file", attempts: f.config.MaxRetries, err: err}
+}
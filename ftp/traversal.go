@@ -0,0 +1,30 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathTraversal is returned when a path reported by the server would resolve outside the directory it belongs in,
+// e.g. a file name containing "../", so nothing is written there.
+var ErrPathTraversal = errors.New("ftp: path escapes its directory")
+
+// isDotEntry is a function that reports whether name is one of the "." and ".." entries some servers include in
+// directory listings. They name the directory itself and its parent, so they are skipped rather than synced or walked
+// into.
+func isDotEntry(name string) bool {
+	return name == "." || name == ".."
+}
+
+// safeJoin is a function that joins name to dir like filepath.Join, but fails with ErrPathTraversal if the cleaned
+// result is not dir or a path below it.
+func safeJoin(dir, name string) (string, error) {
+	joined := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, joined)
+	}
+	return joined, nil
+}
@@ -0,0 +1,60 @@
+package ftp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Validate is a method of the ExtraConfig struct that checks config for the mistakes that would otherwise only surface as
+// confusing failures during the sync.
+//
+// - Returns the problems found, joined with errors.Join, or nil if config is valid.
+func (config *ExtraConfig) Validate() error {
+	if config == nil {
+		return errors.New("ftp: config is nil")
+	}
+	var errs []error
+	if config.LocalDir == "" {
+		errs = append(errs, errors.New("ftp: LocalDir is empty"))
+	} else if info, err := os.Stat(config.LocalDir); err != nil {
+		errs = append(errs, fmt.Errorf("ftp: LocalDir: %w", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("ftp: LocalDir %q is not a directory", config.LocalDir))
+	}
+	if config.RemoteDir == "" {
+		errs = append(errs, errors.New("ftp: RemoteDir is empty"))
+	} else if !strings.HasPrefix(config.RemoteDir, "/") {
+		errs = append(errs, fmt.Errorf("ftp: RemoteDir %q is not an absolute path", config.RemoteDir))
+	}
+	if config.MaxRetries < 1 {
+		errs = append(errs, fmt.Errorf("ftp: MaxRetries is %d, it must be at least 1", config.MaxRetries))
+	}
+	if config.PinnedCertSHA256 != "" {
+		fingerprint, err := hex.DecodeString(normalizeFingerprint(config.PinnedCertSHA256))
+		if err != nil || len(fingerprint) != sha256.Size {
+			errs = append(errs, fmt.Errorf("ftp: PinnedCertSHA256 %q is not a hex SHA-256 fingerprint", config.PinnedCertSHA256))
+		}
+	}
+	if config.OnError < OnErrorAbort || config.OnError > OnErrorCollect {
+		errs = append(errs, fmt.Errorf("ftp: OnError %d is not valid", config.OnError))
+	}
+	if config.LogLevel < Debug || config.LogLevel > Error {
+		errs = append(errs, fmt.Errorf("ftp: LogLevel %d is not valid", config.LogLevel))
+	}
+	if config.Workers < 0 {
+		errs = append(errs, fmt.Errorf("ftp: Workers is %d, it must not be negative", config.Workers))
+	}
+	if config.MinWorkers < 0 {
+		errs = append(errs, fmt.Errorf("ftp: MinWorkers is %d, it must not be negative", config.MinWorkers))
+	}
+	if config.MaxWorkers < 0 {
+		errs = append(errs, fmt.Errorf("ftp: MaxWorkers is %d, it must not be negative", config.MaxWorkers))
+	} else if config.MaxWorkers > 0 && config.MinWorkers > config.MaxWorkers {
+		errs = append(errs, fmt.Errorf("ftp: MinWorkers %d is above MaxWorkers %d", config.MinWorkers, config.MaxWorkers))
+	}
+	return errors.Join(errs...)
+}
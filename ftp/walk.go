@@ -0,0 +1,84 @@
+package ftp
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Walk is a method of the FTP struct that walks the remote tree rooted at remoteDir like filepath.Walk, calling fn for
+// remoteDir and for each file and directory below it, in lexical order within each directory.
+//
+// - ctx stops the walk between two entries; Walk then returns ctx.Err().
+//
+// - remoteDir is the remote directory to walk. fn receives it with a FileInfo that only reports its name and that it
+// is a directory, since servers without MLST cannot stat a directory.
+//
+// - fn is called with the path of each entry, remoteDir joined with the names below it, and its FileInfo. A directory
+// is listed before fn is called for it, and the error of the listing, if any, is passed to fn. Returning
+// filepath.SkipDir from fn for a directory skips its contents, and for a file skips the rest of its directory;
+// returning filepath.SkipAll stops the walk. Any other error stops the walk and is returned by Walk.
+//
+// The "." and ".." entries some servers list are skipped, and so are names that would resolve outside their
+// directory, such as "../name", with a warning.
+func (f *FTP) Walk(ctx context.Context, remoteDir string, fn func(path string, info os.FileInfo, err error) error) error {
+	err := f.walk(ctx, remoteDir, remoteDirInfo{name: path.Base(remoteDir)}, fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walk is a method of the FTP struct that visits dir and, if it is a directory, the entries below it, for Walk.
+func (f *FTP) walk(ctx context.Context, dir string, info os.FileInfo, fn func(path string, info os.FileInfo, err error) error) error {
+	if !info.IsDir() {
+		return fn(dir, info, nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := f.ftpClient().ReadDir(dir)
+	err1 := fn(dir, info, err)
+	// A directory that cannot be listed has no entries to visit, whatever fn returns.
+	if err != nil || err1 != nil {
+		return err1
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if isDotEntry(entry.Name()) {
+			continue
+		}
+		entryPath, err := safeJoin(dir, entry.Name())
+		if err != nil {
+			logger.Warn("Skipping remote file:", err)
+			continue
+		}
+		err = f.walk(ctx, filepath.ToSlash(entryPath), entry, fn)
+		if err != nil {
+			if err == filepath.SkipDir && entry.IsDir() {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteDirInfo is the FileInfo Walk reports for the directory it starts from.
+type remoteDirInfo struct {
+	name string
+}
+
+func (i remoteDirInfo) Name() string       { return i.name }
+func (i remoteDirInfo) Size() int64        { return 0 }
+func (i remoteDirInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (i remoteDirInfo) ModTime() time.Time { return time.Time{} }
+func (i remoteDirInfo) IsDir() bool        { return true }
+func (i remoteDirInfo) Sys() interface{}   { return nil }
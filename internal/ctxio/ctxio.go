@@ -0,0 +1,66 @@
+// Package ctxio makes the copies of io.Reader and io.Writer based transfers stop when their context is canceled.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+// Reader returns a reader reading from r until ctx is done. From then on, Read returns ctx.Err() without reading
+// from r, so an io.Copy from the reader stops after the read in progress instead of copying the rest of r.
+//
+// Parameters:
+//   - ctx: The context the reads stop with.
+//   - r: The reader to read from.
+//
+// Returns:
+//   - io.Reader: The reader. It hides the io.WriterTo of r, if any, so io.Copy reads through it.
+func Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r}
+}
+
+// reader is the io.Reader returned by Reader.
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// CloseOnDone closes c as soon as ctx is done, to interrupt a Read or Write on c blocked on the network, which
+// Reader cannot stop, until the returned function is called.
+//
+// Parameters:
+//   - ctx: The context c is closed with.
+//   - c: The closer, e.g. the remote file of a transfer.
+//
+// Returns:
+//   - func() bool: Stops watching ctx and reports whether c was closed, which it is if ctx is done by then, in
+//     which case the caller must not close it again.
+func CloseOnDone(ctx context.Context, c io.Closer) func() bool {
+	stop := make(chan struct{})
+	closed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+			closed <- true
+		case <-stop:
+			// A canceled ctx may not have been noticed yet, e.g. when the copy stopped at Reader first.
+			done := ctx.Err() != nil
+			if done {
+				_ = c.Close()
+			}
+			closed <- done
+		}
+	}()
+	return func() bool {
+		close(stop)
+		return <-closed
+	}
+}
@@ -0,0 +1,404 @@
+package sftp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cploutarchou/syncpkg/internal/ctxio"
+)
+
+// archiveSuffix is appended to RemoteDir to name the archive ArchiveMode stores next to it during the transfer.
+const archiveSuffix = ".syncpkg-archive"
+
+// archiveExt returns the file extension of the archives compressed with compression.
+func archiveExt(compression string) string {
+	switch compression {
+	case "gz":
+		return ".tar.gz"
+	case "zstd":
+		return ".tar.zst"
+	}
+	return ".tar"
+}
+
+// archiveTarFlag returns the option of the remote tar command that compresses or decompresses with compression.
+func archiveTarFlag(compression string) string {
+	switch compression {
+	case "gz":
+		return " -z"
+	case "zstd":
+		return " --zstd"
+	}
+	return ""
+}
+
+// archiveSync transfers the whole tree as a single archive for SyncOnce with ArchiveMode: the local tree is archived
+// and extracted into RemoteDir by a remote tar command, or the other way round in RemoteToLocal mode. The archive
+// is stored next to RemoteDir during the transfer.
+//
+// Parameters:
+//   - ctx: The context that cancels the transfer.
+//
+// Returns:
+//   - error: If there is no SSH connection to run tar on, or the transfer fails.
+func (s *SFTP) archiveSync(ctx context.Context) error {
+	if s.sshConn == nil {
+		return errors.New("sftp: ArchiveMode needs an SSH connection that can run commands")
+	}
+	remoteTmpPath := path.Clean(filepath.ToSlash(s.config.RemoteDir)) + archiveSuffix + archiveExt(s.config.ArchiveCompression)
+	if s.Direction() == RemoteToLocal {
+		return s.downloadAndExtract(ctx, remoteTmpPath, s.config.LocalDir)
+	}
+	return s.tarAndUpload(ctx, s.config.LocalDir, remoteTmpPath)
+}
+
+// tarAndUpload archives localDir into a local temporary file, uploads it to remoteTmpPath and extracts it into
+// RemoteDir with a remote tar command. The remote archive is removed afterwards.
+//
+// Parameters:
+//   - ctx: The context that cancels the transfer.
+//   - localDir: The local directory to archive.
+//   - remoteTmpPath: The remote path the archive is uploaded to.
+//
+// Returns:
+//   - error: If the archive cannot be written or uploaded, or the remote command fails (the error includes its stderr).
+func (s *SFTP) tarAndUpload(ctx context.Context, localDir, remoteTmpPath string) error {
+	tmp, err := os.CreateTemp("", "syncpkg-archive-*"+archiveExt(s.config.ArchiveCompression))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	entries, err := s.writeArchive(ctx, tmp, localDir)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Uploading %d files as %s", len(entries), remoteTmpPath)
+	dstFile, err := s.Client.Create(remoteTmpPath)
+	if err != nil {
+		return err
+	}
+	defer s.removeArchive(remoteTmpPath)
+	_, err = dstFile.ReadFrom(tmp)
+	closeErr := dstFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("mkdir -p %s && tar -x%s -f %s -C %s", shellQuote(s.config.RemoteDir),
+		archiveTarFlag(s.config.ArchiveCompression), shellQuote(remoteTmpPath), shellQuote(s.config.RemoteDir))
+	err = s.runRemote(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		remotePath := path.Join(filepath.ToSlash(s.config.RemoteDir), entry.name)
+		s.statCache.invalidate(remotePath)
+		s.sendProgress(ProgressEvent{Filename: entry.path, BytesTransferred: entry.size, TotalBytes: entry.size, Done: true})
+		s.stats.record(filepath.FromSlash(entry.name), true, entry.size)
+	}
+	atomic.StoreInt32(&s.uploaded, 1)
+	return nil
+}
+
+// writeArchive writes the directories and regular files below localDir to w as a tar archive, compressed with
+// ArchiveCompression. Temporary files and, without SyncHidden, hidden files are left out.
+//
+// Returns:
+//   - []tarEntry: The files written.
+//   - error: If a file cannot be read or the archive cannot be written.
+func (s *SFTP) writeArchive(ctx context.Context, w io.Writer, localDir string) ([]tarEntry, error) {
+	cw, err := compressor(ctx, w, s.config.ArchiveCompression)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(cw)
+	var entries []tarEntry
+	err = filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if localPath == localDir {
+			return nil
+		}
+		if s.ignored(localPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relativePath, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relativePath)
+		if info.IsDir() {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name + "/"
+			header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+			return tw.WriteHeader(header)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		entry := tarEntry{path: localPath, name: name, size: info.Size(), modTime: info.ModTime()}
+		entries = append(entries, entry)
+		return writeTarEntry(tw, entry)
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	closeErr := cw.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return entries, err
+}
+
+// downloadAndExtract archives RemoteDir into remoteTmpPath with a remote tar command, downloads the archive and
+// extracts it into localDir. The remote archive is removed afterwards.
+//
+// Parameters:
+//   - ctx: The context that cancels the transfer.
+//   - remoteTmpPath: The remote path the archive is written to.
+//   - localDir: The local directory to extract the archive into.
+//
+// Returns:
+//   - error: If the remote command fails (the error includes its stderr), or the archive cannot be downloaded or
+//     extracted.
+func (s *SFTP) downloadAndExtract(ctx context.Context, remoteTmpPath, localDir string) error {
+	command := fmt.Sprintf("tar -c%s -f %s -C %s .", archiveTarFlag(s.config.ArchiveCompression),
+		shellQuote(remoteTmpPath), shellQuote(s.config.RemoteDir))
+	defer s.removeArchive(remoteTmpPath)
+	err := s.runRemote(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	srcFile, err := s.Client.Open(remoteTmpPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+	logger.Debugf("Downloading %s", remoteTmpPath)
+	dr, err := decompressor(ctx, srcFile, s.config.ArchiveCompression)
+	if err != nil {
+		return err
+	}
+	err = s.extractArchive(ctx, dr, localDir)
+	closeErr := dr.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// extractArchive writes the directories and regular files of the tar archive read from r into localDir, with the
+// modification times they have in the archive. Temporary files and, without SyncHidden, hidden files are left out.
+//
+// Returns:
+//   - error: If a file cannot be written, or if a name would escape localDir.
+func (s *SFTP) extractArchive(ctx context.Context, r io.Reader, localDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := path.Clean(header.Name)
+		if name == "." || s.ignored(remoteJoin(s.config.RemoteDir, name)) {
+			continue
+		}
+		localPath, err := safeJoin(localDir, filepath.FromSlash(name))
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = s.dirs.ensure(localPath, func(dir string) error { return os.MkdirAll(dir, 0755) })
+		case tar.TypeReg:
+			err = s.extractArchiveFile(ctx, tr, header, name, localPath)
+		default:
+			logger.Debug("Skipping archive entry that is not a regular file:", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractArchiveFile writes the content of the current file of tr to localPath, stopping when ctx is canceled.
+func (s *SFTP) extractArchiveFile(ctx context.Context, tr *tar.Reader, header *tar.Header, name, localPath string) error {
+	err := s.dirs.ensure(filepath.Dir(localPath), func(dir string) error { return os.MkdirAll(dir, 0755) })
+	if err != nil {
+		return err
+	}
+	file, err := s.createLocal(localPath)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(file, ctxio.Reader(ctx, tr))
+	if err == nil {
+		s.applyRemoteMode(file, header.FileInfo().Mode())
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(localPath, header.ModTime, header.ModTime)
+	}
+	s.sendProgress(ProgressEvent{Filename: localPath, BytesTransferred: n, TotalBytes: header.Size, Done: true})
+	if err != nil {
+		return err
+	}
+	s.stats.record(filepath.FromSlash(name), false, n)
+	return nil
+}
+
+// runRemote runs command on the remote server, closing the session if ctx is canceled.
+//
+// Returns:
+//   - error: If the command fails; the error includes its stderr.
+func (s *SFTP) runRemote(ctx context.Context, command string) error {
+	session, err := s.sshConn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	logger.Debug("Running remote command:", command)
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("remote command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// removeArchive removes the remote archive of ArchiveMode, logging the error if it cannot be removed.
+func (s *SFTP) removeArchive(remoteTmpPath string) {
+	err := s.Client.Remove(remoteTmpPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Error removing remote archive:", err)
+	}
+}
+
+// compressor returns a writer compressing to w with compression: gzip for "gz", the zstd command for "zstd", or none.
+// Closing it flushes the compressed data to w, but does not close w.
+func compressor(ctx context.Context, w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "gz":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		cmd := exec.CommandContext(ctx, "zstd", "-q", "-c")
+		cmd.Stdout = w
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+		return &cmdPipe{WriteCloser: stdin, cmd: cmd}, nil
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// decompressor returns a reader decompressing r with compression, the counterpart of compressor.
+func decompressor(ctx context.Context, r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case "gz":
+		return gzip.NewReader(r)
+	case "zstd":
+		cmd := exec.CommandContext(ctx, "zstd", "-q", "-d", "-c")
+		cmd.Stdin = r
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+		return &cmdPipe{ReadCloser: stdout, cmd: cmd}, nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// cmdPipe is the standard input or output of a running command; Close closes it and waits for the command.
+type cmdPipe struct {
+	io.WriteCloser
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// Close closes the pipe and waits for the command to exit.
+func (p *cmdPipe) Close() error {
+	if p.WriteCloser != nil {
+		_ = p.WriteCloser.Close()
+	} else {
+		// Drain the output so the command is not blocked writing it.
+		_, _ = io.Copy(io.Discard, p.ReadCloser)
+	}
+	return p.cmd.Wait()
+}
+
+// nopWriteCloser is an io.Writer with a Close method that does nothing.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopWriteCloser) Close() error { return nil }
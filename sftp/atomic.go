@@ -0,0 +1,83 @@
+package sftp
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// atomicUploadSuffix is appended to the remote path a file is uploaded to when AtomicUploads is set.
+const atomicUploadSuffix = ".synctmp"
+
+// uploadTarget returns the remote path a file destined for remotePath is written to: a temporary path next to
+// it when AtomicUploads is set, remotePath itself otherwise.
+func (s *SFTP) uploadTarget(remotePath string) string {
+	if s.config.AtomicUploads {
+		return remotePath + atomicUploadSuffix
+	}
+	return remotePath
+}
+
+// commitUpload moves a fully written upload from its temporary path to remotePath when AtomicUploads is set.
+// The posix-rename extension replaces remotePath in one step; servers without it get the existing file removed
+// first, which leaves a short window where remotePath is missing but never partially written.
+//
+// Parameters:
+//   - client: The SFTP client the file was uploaded with.
+//   - remotePath: The final path of the remote file.
+//
+// Returns:
+//   - error: If the temporary file cannot be renamed.
+func (s *SFTP) commitUpload(client *sftp.Client, remotePath string) error {
+	if !s.config.AtomicUploads {
+		return nil
+	}
+	tmpPath := remotePath + atomicUploadSuffix
+	err := client.PosixRename(tmpPath, remotePath)
+	if err == nil {
+		return nil
+	}
+	if err := client.Remove(remotePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return client.Rename(tmpPath, remotePath)
+}
+
+// abortUpload removes the temporary file of a failed upload to remotePath when AtomicUploads is set.
+func (s *SFTP) abortUpload(client *sftp.Client, remotePath string) {
+	if !s.config.AtomicUploads {
+		return
+	}
+	err := client.Remove(remotePath + atomicUploadSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error("Error removing temporary upload:", err)
+	}
+}
+
+// removeStaleUploads removes the temporary files left in remoteDir by atomic uploads that were interrupted,
+// e.g. by a crash. entries is the listing of remoteDir from readRemoteDirCache, or nil to list it.
+func (s *SFTP) removeStaleUploads(remoteDir string, entries map[string]os.FileInfo) {
+	if entries == nil {
+		list, err := s.Client.ReadDir(remoteDir)
+		if err != nil {
+			return
+		}
+		entries = make(map[string]os.FileInfo, len(list))
+		for _, entry := range list {
+			entries[entry.Name()] = entry
+		}
+	}
+	for name, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(name, atomicUploadSuffix) {
+			continue
+		}
+		stale := remoteJoin(remoteDir, name)
+		logger.Info("Removing stale temporary upload:", stale)
+		err := s.Client.Remove(stale)
+		if err != nil {
+			logger.Error("Error removing stale temporary upload:", err)
+		}
+		s.statCache.invalidate(stale)
+	}
+}
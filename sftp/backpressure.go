@@ -0,0 +1,127 @@
+package sftp
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// eventBufferFactor is how many times the capacity of the task queue the event buffer holds.
+const eventBufferFactor = 8
+
+// eventFeed buffers the events of the fsnotify watcher on their way to the worker pool, so the watcher's read loop
+// never waits for a busy pool and the kernel queue of the watcher does not overflow.
+type eventFeed struct {
+	//events are the buffered tasks
+	events chan worker.Task
+	//rescan is signaled when events were lost, by a full buffer or an overflow of the kernel queue
+	rescan chan struct{}
+}
+
+// newEventFeed returns an eventFeed whose buffer holds eventBufferFactor times the capacity of the task queue.
+func (s *SFTP) newEventFeed() *eventFeed {
+	return &eventFeed{
+		events: make(chan worker.Task, eventBufferFactor*cap(s.Pool.Tasks)),
+		rescan: make(chan struct{}, 1),
+	}
+}
+
+// push buffers task without blocking. If the buffer is full, the task is dropped and a rescan requested instead.
+func (f *eventFeed) push(task worker.Task) {
+	select {
+	case f.events <- task:
+	default:
+		logger.Debug("Event buffer full, dropping event:", task.Name)
+		f.overflow()
+	}
+}
+
+// overflow requests a rescan to recover the events that were lost.
+func (f *eventFeed) overflow() {
+	select {
+	case f.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// feed hands the buffered tasks over to the worker pool, waiting for room in the pool, and runs the rescans
+// requested by the feed, until ctx is canceled.
+//
+// Parameters:
+//   - ctx: The context of the watch session.
+//   - f: The feed of the fsnotify watcher.
+//   - watcher: The fsnotify watcher, to add the directories created while events were lost.
+//   - since: The start of the watch session; the first rescan looks for files modified after it.
+func (s *SFTP) feed(ctx context.Context, f *eventFeed, watcher *fsnotify.Watcher, since time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-f.events:
+			s.enqueue(task)
+		case <-f.rescan:
+			started := s.clock().Now()
+			err := s.rescan(watcher, since)
+			if err != nil {
+				logger.Error("Error rescanning after lost events:", err)
+				continue
+			}
+			since = started
+		}
+	}
+}
+
+// rescan recovers the changes whose events were lost: the local files modified after since are queued for upload,
+// the remote files missing locally are queued for removal, and the local directories are added to the watcher again.
+// Only LocalToRemote syncs are driven by the watcher, so rescan does nothing in RemoteToLocal mode.
+//
+// Parameters:
+//   - watcher: The fsnotify watcher, or nil.
+//   - since: The time from which events may have been lost. A second is subtracted for file systems with coarse
+//     modification times.
+//
+// Returns:
+//   - error: If a directory cannot be walked or watched.
+func (s *SFTP) rescan(watcher *fsnotify.Watcher, since time.Time) error {
+	if s.Direction() != LocalToRemote {
+		return nil
+	}
+	logger.Warn("Events were lost, rescanning the local directory...")
+	if watcher != nil {
+		err := s.AddDirectoriesToWatcher(watcher, s.config.LocalDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	localFiles := make(map[string]os.FileInfo)
+	err := walkLocalDir(s.config.LocalDir, localFiles)
+	if err != nil {
+		return err
+	}
+	since = since.Add(-time.Second)
+	for path, info := range localFiles {
+		if !s.ignored(path) && info.ModTime().After(since) {
+			s.enqueue(worker.Task{EventType: fsnotify.Write, Name: path})
+		}
+	}
+
+	remoteFiles := make(map[string]os.FileInfo)
+	err = s.walkRemoteDir(s.config.RemoteDir, remoteFiles)
+	if err != nil {
+		return err
+	}
+	for remotePath := range remoteFiles {
+		localPath, err := s.localPath(remotePath)
+		if err != nil || s.ignored(localPath) {
+			continue
+		}
+		if _, ok := localFiles[localPath]; !ok {
+			s.enqueue(worker.Task{EventType: fsnotify.Remove, Name: localPath})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package sftp
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/sftp"
+)
+
+// defaultSmallFileThreshold is used when ExtraConfig.SmallFileThreshold is not set.
+const defaultSmallFileThreshold = 4096
+
+// smallFileBatchWindow is how long the batch worker waits for another small file before uploading the batch.
+const smallFileBatchWindow = 50 * time.Millisecond
+
+// maxSmallFileBatch is the maximum number of files uploaded in a single batch.
+const maxSmallFileBatch = 64
+
+// smallFileThreshold returns the configured SmallFileThreshold or defaultSmallFileThreshold when unset.
+func (s *SFTP) smallFileThreshold() int64 {
+	if s.config.SmallFileThreshold > 0 {
+		return s.config.SmallFileThreshold
+	}
+	return defaultSmallFileThreshold
+}
+
+// startBatchWorker starts the goroutine that uploads small files in batches when BatchSmallFiles is set.
+// It must be called before the workers are started.
+func (s *SFTP) startBatchWorker() {
+	if !s.config.BatchSmallFiles {
+		return
+	}
+	s.smallFiles = make(chan worker.Task, cap(s.Pool.Tasks))
+	go s.batchWorker()
+}
+
+// batchSmallFile hands an upload task over to the batch worker if the file is small enough.
+//
+// Parameters:
+//   - task: The task being processed by a worker.
+//   - direction: The sync direction the task is processed in.
+//
+// Returns:
+//   - bool: True if the batch worker took over the task, in which case it also ends the task.
+func (s *SFTP) batchSmallFile(task worker.Task, direction SyncDirection) bool {
+	if s.smallFiles == nil || direction != LocalToRemote {
+		return false
+	}
+	if task.EventType != fsnotify.Create && task.EventType != fsnotify.Write {
+		return false
+	}
+	info, err := os.Stat(task.Name)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > s.smallFileThreshold() {
+		return false
+	}
+	s.smallFiles <- task
+	return true
+}
+
+// batchWorker collects small-file tasks until none arrives for smallFileBatchWindow, or maxSmallFileBatch
+// are collected, and uploads them together with uploadBatch.
+func (s *SFTP) batchWorker() {
+	for first := range s.smallFiles {
+		batch := []worker.Task{first}
+	collect:
+		for len(batch) < maxSmallFileBatch {
+			select {
+			case task := <-s.smallFiles:
+				batch = append(batch, task)
+			case <-s.clock().After(smallFileBatchWindow):
+				break collect
+			}
+		}
+
+		s.uploadBatch(batch)
+		for range batch {
+			s.endTask()
+		}
+		s.runPostUploadIfIdle()
+		for range batch {
+			s.Pool.Done()
+		}
+	}
+}
+
+// uploadBatch uploads the files of batch concurrently over a single SFTP session, so the per-file overhead
+// of the workers is paid once per batch. Errors are logged per file.
+func (s *SFTP) uploadBatch(batch []worker.Task) {
+	if len(s.conns) < 2 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	client, release := s.acquire(0)
+	defer release()
+
+	logger.Debugf("Uploading a batch of %d small files", len(batch))
+	var wg sync.WaitGroup
+	for _, task := range batch {
+		wg.Add(1)
+		go func(task worker.Task) {
+			defer wg.Done()
+			start := s.clock().Now()
+			err := s.uploadSmallFile(client, task.Name)
+			s.Pool.RecordTask(s.clock(), start, err)
+			if err != nil {
+				logger.Errorf("Error uploading file (attempt %d): %v", task.RetryCount+1, err)
+				s.retryTask(task, err)
+				return
+			}
+			s.journalDone(task)
+		}(task)
+	}
+	wg.Wait()
+}
+
+// uploadSmallFile reads a small file at once and writes it to the remote directory.
+//
+// Parameters:
+//   - client: The SFTP client shared by the batch.
+//   - filePath: The path of the file in the local directory to upload.
+//
+// Returns:
+//   - error: If the file cannot be read or written to the remote server.
+func (s *SFTP) uploadSmallFile(client *sftp.Client, filePath string) error {
+	relativePath, err := filepath.Rel(s.config.LocalDir, filePath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	remotePath := remoteJoin(s.config.RemoteDir, relativePath)
+	defer s.lockFile(remotePath)()
+	defer s.statCache.invalidate(remotePath)
+	err = s.dirs.ensure(path.Dir(remotePath), func(dir string) error { return mkdirAllRemote(client, dir, s.config.DirMode) })
+	if err != nil {
+		return err
+	}
+	dstFile, err := client.OpenFile(s.uploadTarget(remotePath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	n, err := dstFile.Write(data)
+	closeErr := dstFile.Close()
+	s.sendProgress(ProgressEvent{Filename: filePath, BytesTransferred: int64(n), TotalBytes: int64(len(data)), Done: true})
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.commitUpload(client, remotePath)
+	}
+	if err != nil {
+		s.abortUpload(client, remotePath)
+		return err
+	}
+	s.stats.record(relativePath, true, int64(n))
+	atomic.StoreInt32(&s.uploaded, 1)
+	return nil
+}
@@ -0,0 +1,22 @@
+package sftp
+
+// defaultCopyBufferSize is used when ExtraConfig.CopyBufferSize is not set.
+const defaultCopyBufferSize = 1 << 20
+
+// copyBufferSize returns the configured CopyBufferSize or defaultCopyBufferSize when unset.
+func (s *SFTP) copyBufferSize() int {
+	if s.config.CopyBufferSize > 0 {
+		return s.config.CopyBufferSize
+	}
+	return defaultCopyBufferSize
+}
+
+// getBuffer returns a transfer buffer of copyBufferSize bytes. Buffers are returned to s.buffers after
+// each transfer, so each worker reuses a buffer instead of allocating one per file.
+func (s *SFTP) getBuffer() *[]byte {
+	if buf, ok := s.buffers.Get().(*[]byte); ok && len(*buf) == s.copyBufferSize() {
+		return buf
+	}
+	buf := make([]byte, s.copyBufferSize())
+	return &buf
+}
@@ -0,0 +1,73 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// CaseCollisionPolicy selects what the initial sync does with entries of a source directory whose names differ only
+// in case, which would overwrite each other on a case-insensitive destination such as macOS or Windows.
+//
+// Entries are taken in name order, so the first of the colliding names keeps its name on every run.
+type CaseCollisionPolicy int
+
+const (
+	//CaseCollisionOff does not look for collisions: every entry is transferred under its own name
+	CaseCollisionOff CaseCollisionPolicy = iota
+	//CaseCollisionError fails the sync with ErrCaseCollision
+	CaseCollisionError
+	//CaseCollisionSkip transfers the first entry and skips the others, with a warning
+	CaseCollisionSkip
+	//CaseCollisionRename transfers the others under a name with a ~N suffix before the extension, e.g. file~1.txt
+	CaseCollisionRename
+)
+
+// ErrCaseCollision is returned by the initial sync with CaseCollisionError when two entries of a source directory
+// have names that differ only in case.
+var ErrCaseCollision = errors.New("sftp: names differ only in case")
+
+// caseNames holds the lowercased names already transferred into a destination directory.
+type caseNames map[string]string
+
+// resolveCaseCollision applies the CaseCollisionPolicy to an entry of a source directory.
+//
+// Parameters:
+//   - seen: The names already transferred into the destination directory, updated with the name returned.
+//   - dir: The source directory, for the messages.
+//   - name: The name of the entry.
+//
+// Returns:
+//   - string: The name to transfer the entry under.
+//   - bool: False if the entry must be skipped.
+//   - error: ErrCaseCollision with CaseCollisionError.
+func (s *SFTP) resolveCaseCollision(seen caseNames, dir, name string) (string, bool, error) {
+	policy := s.config.CaseCollisionPolicy
+	if policy == CaseCollisionOff {
+		return name, true, nil
+	}
+	first, collides := seen[strings.ToLower(name)]
+	if !collides {
+		seen[strings.ToLower(name)] = name
+		return name, true, nil
+	}
+
+	switch policy {
+	case CaseCollisionError:
+		return "", false, fmt.Errorf("%w: %s and %s in %s", ErrCaseCollision, first, name, dir)
+	case CaseCollisionSkip:
+		logger.Warnf("Skipping %s in %s, its name collides with %s", name, dir, first)
+		return "", false, nil
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		renamed := fmt.Sprintf("%s~%d%s", stem, i, ext)
+		if _, taken := seen[strings.ToLower(renamed)]; !taken {
+			seen[strings.ToLower(renamed)] = renamed
+			logger.Warnf("Transferring %s in %s as %s, its name collides with %s", name, dir, renamed, first)
+			return renamed, true, nil
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package sftp
+
+import "errors"
+
+// Close stops WatchDirectory, lets the workers finish the tasks already queued and then closes the SFTP client
+// and the SSH connections under it, including the connection to the JumpHost. A SharedTransport is left open.
+// The journal, if any, is compacted and closed last.
+//
+// A paused pool is resumed so its workers can drain the queue. Calling Close again returns the same result.
+//
+// Returns:
+//   - error: The errors of the clients, connections and journal that could not be closed, joined with errors.Join.
+//     Every connection is closed even if closing another fails.
+func (s *SFTP) Close() error {
+	s.closeOnce.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+		s.Pool.Resume()
+		s.Pool.Close()
+		s.closeErr = s.closeConns()
+		if s.journal != nil {
+			s.closeErr = errors.Join(s.closeErr, s.journal.Close())
+		}
+	})
+	return s.closeErr
+}
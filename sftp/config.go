@@ -0,0 +1,78 @@
+package sftp
+
+import (
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// NewExtraConfig returns an ExtraConfig for syncing localDir with remoteDir as username, with the defaults the zero
+// value does not provide: 3 retries, a poll interval of 5 seconds, SyncHidden, SkipSpecialFiles, and watcher events
+// queued with worker.PriorityHigh.
+//
+// Parameters:
+//   - localDir: The local directory to sync.
+//   - remoteDir: The remote directory to sync.
+//   - username: The user to authenticate as.
+//   - password: The password to authenticate with.
+//
+// Returns:
+//   - *ExtraConfig: The config, which the caller can adjust before passing it to Connect.
+func NewExtraConfig(localDir, remoteDir, username, password string) *ExtraConfig {
+	return &ExtraConfig{
+		Username:         username,
+		Password:         password,
+		LocalDir:         localDir,
+		RemoteDir:        remoteDir,
+		Retries:          3,
+		MaxRetries:       3,
+		PollInterval:     5 * time.Second,
+		SyncHidden:       true,
+		SkipSpecialFiles: true,
+		EventPriority:    worker.PriorityHigh,
+	}
+}
+
+// Clone returns a copy of config, to derive a modified config without changing the original. The TempFilePatterns,
+// IncludeExtensions and ExcludeExtensions slices and the JumpHost are copied too; SharedTransport and Clock are
+// shared.
+//
+// Returns:
+//   - *ExtraConfig: The copy, or nil if config is nil.
+func (config *ExtraConfig) Clone() *ExtraConfig {
+	if config == nil {
+		return nil
+	}
+	clone := *config
+	if config.TempFilePatterns != nil {
+		clone.TempFilePatterns = append([]string(nil), config.TempFilePatterns...)
+	}
+	if config.IncludeExtensions != nil {
+		clone.IncludeExtensions = append([]string(nil), config.IncludeExtensions...)
+	}
+	if config.ExcludeExtensions != nil {
+		clone.ExcludeExtensions = append([]string(nil), config.ExcludeExtensions...)
+	}
+	if config.JumpHost != nil {
+		jumpHost := *config.JumpHost
+		clone.JumpHost = &jumpHost
+	}
+	return &clone
+}
+
+// workers returns the capacity of the worker pool: Workers, or 10 if it is not set.
+func (config *ExtraConfig) workers() int {
+	if config.Workers > 0 {
+		return config.Workers
+	}
+	return 10
+}
+
+// poolConfig returns the config of the worker pool, with AdaptiveWorkers between MinWorkers and MaxWorkers.
+func (config *ExtraConfig) poolConfig() worker.PoolConfig {
+	return worker.PoolConfig{
+		AdaptiveWorkers: config.AdaptiveWorkers,
+		MinWorkers:      config.MinWorkers,
+		MaxWorkers:      config.MaxWorkers,
+	}
+}
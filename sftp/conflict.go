@@ -0,0 +1,163 @@
+package sftp
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// conflictSuffix separates the name of a file from the timestamp in the name of its conflict backups.
+const conflictSuffix = ".conflict-"
+
+// conflictTimeFormat is the format of the timestamp in the name of conflict backups, in UTC.
+const conflictTimeFormat = "20060102T150405Z"
+
+// writtenFiles records the size and modification time of the destination files the sync wrote, so the next write
+// of a file can tell whether it was changed by someone else meanwhile, see ExtraConfig.ConflictBackups.
+type writtenFiles struct {
+	mu    sync.Mutex
+	files map[string]writtenFile
+}
+
+// writtenFile is the state of a destination file right after the sync wrote it.
+type writtenFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// record records the state of the file at path, as the sync just wrote it.
+func (w *writtenFiles) record(path string, info os.FileInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.files == nil {
+		w.files = make(map[string]writtenFile)
+	}
+	w.files[filepath.Clean(path)] = writtenFile{size: info.Size(), modTime: info.ModTime()}
+}
+
+// changed reports whether the file at path, now described by info, was written by the sync and changed since.
+// A file the sync never wrote is not reported, so the first sync overwrites the files already in place as before.
+func (w *writtenFiles) changed(path string, info os.FileInfo) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	written, ok := w.files[filepath.Clean(path)]
+	return ok && (written.size != info.Size() || !written.modTime.Equal(info.ModTime()))
+}
+
+// isConflictBackup reports whether ConflictBackups is set and path is named like a conflict backup, so the
+// backups are neither synced nor removed by TriggerFullSync as files missing from the source.
+func (s *SFTP) isConflictBackup(path string) bool {
+	if !s.config.ConflictBackups {
+		return false
+	}
+	name := filepath.Base(path)
+	i := strings.LastIndex(name, conflictSuffix)
+	if i < 0 {
+		return false
+	}
+	_, err := time.Parse(conflictTimeFormat, name[i+len(conflictSuffix):])
+	return err == nil
+}
+
+// conflictBackupPath returns the path the conflict backup of a destination file is moved to.
+//
+// Parameters:
+//   - destination: The path of the file, below root.
+//   - root: The destination directory, RemoteDir or LocalDir.
+//   - join: The function joining paths on the destination side.
+//
+// Returns:
+//   - string: The path of the backup, next to the file or below ConflictBackupDir.
+func (s *SFTP) conflictBackupPath(destination, root string, join func(elem ...string) string) string {
+	suffix := conflictSuffix + s.clock().Now().UTC().Format(conflictTimeFormat)
+	if s.config.ConflictBackupDir == "" {
+		return destination + suffix
+	}
+	relativePath, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(destination))
+	if err != nil {
+		relativePath = filepath.Base(destination)
+	}
+	dir := s.config.ConflictBackupDir
+	if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "/") {
+		dir = join(root, dir)
+	}
+	return join(dir, filepath.ToSlash(relativePath)+suffix)
+}
+
+// backupRemoteConflict moves the remote file an upload is about to overwrite to a conflict backup if it was changed
+// since the sync last wrote it. It does nothing unless ConflictBackups is set.
+//
+// Parameters:
+//   - client: The client of the connection the upload runs on.
+//   - remotePath: The path of the remote file.
+//
+// Returns:
+//   - error: If the backup cannot be made; the file is then not overwritten.
+func (s *SFTP) backupRemoteConflict(client *sftp.Client, remotePath string) error {
+	if !s.config.ConflictBackups {
+		return nil
+	}
+	info, err := client.Stat(remotePath)
+	if err != nil || !info.Mode().IsRegular() || !s.written.changed(remotePath, info) {
+		return nil
+	}
+	backup := s.conflictBackupPath(remotePath, s.config.RemoteDir, path.Join)
+	err = s.dirs.ensure(path.Dir(backup), func(dir string) error { return mkdirAllRemote(client, dir, s.config.DirMode) })
+	if err != nil {
+		return err
+	}
+	logger.Warn("Remote file changed since it was synced, keeping it as", backup)
+	return client.Rename(remotePath, backup)
+}
+
+// backupLocalConflict moves the local file a download is about to overwrite to a conflict backup if it was changed
+// since the sync last wrote it. It does nothing unless ConflictBackups is set.
+//
+// Parameters:
+//   - localPath: The path of the local file.
+//
+// Returns:
+//   - error: If the backup cannot be made; the file is then not overwritten.
+func (s *SFTP) backupLocalConflict(localPath string) error {
+	if !s.config.ConflictBackups {
+		return nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() || !s.written.changed(localPath, info) {
+		return nil
+	}
+	backup := s.conflictBackupPath(localPath, s.config.LocalDir, filepath.Join)
+	err = s.dirs.ensure(filepath.Dir(backup), func(dir string) error { return os.MkdirAll(dir, 0755) })
+	if err != nil {
+		return err
+	}
+	logger.Warn("Local file changed since it was synced, keeping it as", backup)
+	return os.Rename(localPath, backup)
+}
+
+// recordRemoteWrite records the state of the remote file an upload just wrote, for backupRemoteConflict. It does
+// nothing unless ConflictBackups is set.
+func (s *SFTP) recordRemoteWrite(client *sftp.Client, remotePath string) {
+	if !s.config.ConflictBackups {
+		return
+	}
+	if info, err := client.Stat(remotePath); err == nil {
+		s.written.record(remotePath, info)
+	}
+}
+
+// recordLocalWrite records the state of the local file a download just wrote, for backupLocalConflict. It does
+// nothing unless ConflictBackups is set.
+func (s *SFTP) recordLocalWrite(file *os.File) {
+	if !s.config.ConflictBackups {
+		return
+	}
+	if info, err := file.Stat(); err == nil {
+		s.written.record(file.Name(), info)
+	}
+}
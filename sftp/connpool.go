@@ -0,0 +1,152 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionStats holds the traffic counters of one SSH connection of the pool.
+type ConnectionStats struct {
+	//BytesSent is the number of bytes written to the connection, including SSH framing
+	BytesSent int64
+	//BytesReceived is the number of bytes read from the connection, including SSH framing
+	BytesReceived int64
+	//ActiveSessions is the number of transfers currently running over the connection
+	ActiveSessions int64
+}
+
+// pooledConn is one SSH connection of the pool and the SFTP client running over it.
+type pooledConn struct {
+	client         *sftp.Client
+	ssh            *ssh.Client
+	conn           *countingConn
+	activeSessions int64
+}
+
+// countingConn counts the bytes read from and written to a net.Conn.
+type countingConn struct {
+	net.Conn
+	sent     int64
+	received int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.received, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.sent, int64(n))
+	return n, err
+}
+
+// dialPool opens count independent SSH connections to addr, each with its own SFTP client.
+//
+// Parameters:
+//   - dial: The function opening the network connections, see newDialer.
+//   - addr: The host:port of the remote SFTP server.
+//   - clientConfig: The SSH client configuration used for every connection.
+//   - count: The number of connections to open; values below 1 open a single connection.
+//
+// Returns:
+//   - []*pooledConn: The connections of the pool.
+//   - error: If any connection cannot be established, in which case the ones already open are closed.
+func dialPool(dial dialFunc, addr string, clientConfig *ssh.ClientConfig, count int) ([]*pooledConn, error) {
+	if count < 1 {
+		count = 1
+	}
+	conns := make([]*pooledConn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, err := dialConn(dial, addr, clientConfig)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.client.Close()
+				_ = c.ssh.Close()
+			}
+			return nil, fmt.Errorf("ssh connection %d/%d: %w", i+1, count, err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+// dialConn opens a single SSH connection to addr with dial and starts an SFTP client over it.
+func dialConn(dial dialFunc, addr string, clientConfig *ssh.ClientConfig) (*pooledConn, error) {
+	netConn, err := dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	counted := &countingConn{Conn: netConn}
+	sshConn, chans, reqs, err := ssh.NewClientConn(counted, addr, clientConfig)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		_ = sshClient.Close()
+		return nil, err
+	}
+	return &pooledConn{client: client, ssh: sshClient, conn: counted}, nil
+}
+
+// acquire returns the SFTP client the worker in slot should use for a transfer, and a function
+// that must be called once the transfer is done.
+//
+// Without a connection pool every transfer uses Client. With a pool, slot i uses connection
+// i % SSHConnectionCount so the workers spread their transfers over all connections.
+func (s *SFTP) acquire(slot int) (*sftp.Client, func()) {
+	if len(s.conns) == 0 {
+		return s.Client, func() {}
+	}
+	conn := s.conns[slot%len(s.conns)]
+	atomic.AddInt64(&conn.activeSessions, 1)
+	return conn.client, func() {
+		atomic.AddInt64(&conn.activeSessions, -1)
+	}
+}
+
+// ConnectionPoolStats returns the traffic counters of each SSH connection of the pool,
+// in the order the connections were opened.
+//
+// Returns:
+//   - []ConnectionStats: One entry per connection, or nil if the SFTP was not created by Connect or ConnectSSHPair.
+func (s *SFTP) ConnectionPoolStats() []ConnectionStats {
+	if len(s.conns) == 0 {
+		return nil
+	}
+	stats := make([]ConnectionStats, len(s.conns))
+	for i, conn := range s.conns {
+		stats[i] = ConnectionStats{
+			BytesSent:      atomic.LoadInt64(&conn.conn.sent),
+			BytesReceived:  atomic.LoadInt64(&conn.conn.received),
+			ActiveSessions: atomic.LoadInt64(&conn.activeSessions),
+		}
+	}
+	return stats
+}
+
+// closeConns closes the SFTP clients and SSH connections of the pool, or Client if there is no pool,
+// and then the connection to the jump host.
+func (s *SFTP) closeConns() error {
+	if len(s.conns) == 0 {
+		return s.Client.Close()
+	}
+	var errs []error
+	for _, conn := range s.conns {
+		errs = append(errs, conn.client.Close(), conn.ssh.Close())
+	}
+	if s.jump != nil {
+		errs = append(errs, s.jump.Close())
+	}
+	return errors.Join(errs...)
+}
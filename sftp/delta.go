@@ -0,0 +1,95 @@
+package sftp
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/sftp"
+)
+
+// deltaBlockSize is the size of the blocks compared by uploadDelta.
+const deltaBlockSize = 64 << 10
+
+// uploadDelta updates an existing remote file in place, writing only the blocks that differ from the local file
+// and truncating the remote file to the local size.
+//
+// SFTP cannot compute checksums on the server, so the blocks are compared at the same offsets after reading
+// them from the remote file. Changes that shift the content, such as insertions, rewrite every following block.
+//
+// Parameters:
+//   - client: The SFTP client used for the transfer.
+//   - srcFile: The open local file.
+//   - remotePath: The path of the remote file.
+//
+// Returns:
+//   - int64: The number of bytes written to the remote file.
+//   - bool: False if the remote file does not exist or cannot be read in blocks, in which case nothing was written
+//     and the caller must fall back to a full transfer.
+//   - error: If the delta transfer failed after writing to the remote file.
+func (s *SFTP) uploadDelta(client *sftp.Client, srcFile *os.File, remotePath string) (int64, bool, error) {
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return 0, false, nil
+	}
+	dstFile, err := client.OpenFile(remotePath, os.O_RDWR)
+	if err != nil {
+		return 0, false, nil
+	}
+	defer func(dstFile *sftp.File) {
+		err := dstFile.Close()
+		if err != nil {
+			logger.Error("Error closing file:", err)
+		}
+	}(dstFile)
+	dstInfo, err := dstFile.Stat()
+	if err != nil || dstInfo.Size() == 0 {
+		return 0, false, nil
+	}
+
+	size := srcInfo.Size()
+	local := make([]byte, deltaBlockSize)
+	remote := make([]byte, deltaBlockSize)
+	var written int64
+	for offset := int64(0); offset < size; offset += deltaBlockSize {
+		if s.ctx.Err() != nil {
+			return written, true, s.ctx.Err()
+		}
+		n, err := srcFile.ReadAt(local, offset)
+		if err != nil && err != io.EOF {
+			return written, true, err
+		}
+		block := local[:n]
+
+		var m int
+		if offset < dstInfo.Size() {
+			m, err = dstFile.ReadAt(remote[:n], offset)
+			if err != nil && err != io.EOF {
+				if written == 0 {
+					// The server cannot serve block reads, so transfer the whole file instead.
+					return 0, false, nil
+				}
+				return written, true, err
+			}
+		}
+		if m == n && bytes.Equal(block, remote[:m]) {
+			continue
+		}
+
+		_, err = dstFile.WriteAt(block, offset)
+		if err != nil {
+			return written, true, err
+		}
+		written += int64(n)
+		s.sendProgress(ProgressEvent{Filename: srcFile.Name(), BytesTransferred: written, TotalBytes: size})
+	}
+
+	if dstInfo.Size() > size {
+		err = dstFile.Truncate(size)
+		if err != nil {
+			return written, true, err
+		}
+	}
+	s.sendProgress(ProgressEvent{Filename: srcFile.Name(), BytesTransferred: written, TotalBytes: size, Done: true})
+	return written, true, nil
+}
@@ -0,0 +1,60 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrTransferInProgress is returned by SetDirection while tasks are queued or being processed.
+var ErrTransferInProgress = errors.New("sftp: transfer in progress")
+
+// Direction returns the current direction of the sync operation.
+func (s *SFTP) Direction() SyncDirection {
+	s.dirMu.RLock()
+	defer s.dirMu.RUnlock()
+	return s.direction
+}
+
+// SetDirection changes the direction of the sync operation at runtime, e.g. to switch to pushing
+// local changes after an initial pull. If WatchDirectory is running, the watch is restarted in the
+// new direction, starting with an initial sync.
+//
+// Parameters:
+//   - d: The new direction, either LocalToRemote or RemoteToLocal.
+//
+// Returns:
+//   - error: ErrTransferInProgress if tasks are queued or being processed, so no transfer is
+//     carried out in a different direction than it was queued for.
+func (s *SFTP) SetDirection(d SyncDirection) error {
+	if d != LocalToRemote && d != RemoteToLocal {
+		return fmt.Errorf("sftp: invalid sync direction %d", d)
+	}
+
+	s.dirMu.Lock()
+	defer s.dirMu.Unlock()
+	if d == s.direction {
+		return nil
+	}
+	if atomic.LoadInt64(&s.activeTasks) > 0 || s.Pool.Pending() > 0 {
+		return ErrTransferInProgress
+	}
+	s.direction = d
+	if s.restart != nil {
+		s.restart()
+	}
+	return nil
+}
+
+// beginTask marks a task as being processed and returns the direction to process it in.
+func (s *SFTP) beginTask() SyncDirection {
+	s.dirMu.RLock()
+	defer s.dirMu.RUnlock()
+	atomic.AddInt64(&s.activeTasks, 1)
+	return s.direction
+}
+
+// endTask marks a task started with beginTask as processed.
+func (s *SFTP) endTask() {
+	atomic.AddInt64(&s.activeTasks, -1)
+}
@@ -0,0 +1,67 @@
+package sftp
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dirGate makes sure the parent directory of a file exists before the file is transferred. Concurrent workers
+// transferring files into the same new directory wait for a single creation of it, and directories known to exist
+// are not checked again.
+type dirGate struct {
+	mu   sync.Mutex
+	dirs map[string]*dirEntry
+}
+
+// dirEntry is the creation of a directory, done once done is closed.
+type dirEntry struct {
+	done chan struct{}
+	err  error
+}
+
+// ensure creates dir with create, unless it was already created, and waits for a creation in progress.
+//
+// Parameters:
+//   - dir: The directory that must exist.
+//   - create: The function that creates dir and its parents, like os.MkdirAll or mkdirAllRemote.
+//
+// Returns:
+//   - error: If dir could not be created. A failed creation is attempted again by the next call.
+func (g *dirGate) ensure(dir string, create func(string) error) error {
+	dir = filepath.Clean(dir)
+	g.mu.Lock()
+	if g.dirs == nil {
+		g.dirs = make(map[string]*dirEntry)
+	}
+	entry, ok := g.dirs[dir]
+	if ok {
+		g.mu.Unlock()
+		<-entry.done
+		return entry.err
+	}
+	entry = &dirEntry{done: make(chan struct{})}
+	g.dirs[dir] = entry
+	g.mu.Unlock()
+
+	entry.err = create(dir)
+	if entry.err != nil {
+		g.mu.Lock()
+		delete(g.dirs, dir)
+		g.mu.Unlock()
+	}
+	close(entry.done)
+	return entry.err
+}
+
+// forget drops dir and the directories below it, e.g. because they were removed, so they are created again when needed.
+func (g *dirGate) forget(dir string) {
+	dir = filepath.Clean(dir)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for d := range g.dirs {
+		if d == dir || strings.HasPrefix(d, dir+"/") {
+			delete(g.dirs, d)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package sftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// extensionFiltered reports whether the file at path is left out of the sync by IncludeExtensions or
+// ExcludeExtensions. It must only be called for files, since directories are synced whatever their name.
+func (s *SFTP) extensionFiltered(path string) bool {
+	ext := filepath.Ext(path)
+	if len(s.config.IncludeExtensions) > 0 && !hasExtension(s.config.IncludeExtensions, ext) {
+		return true
+	}
+	return hasExtension(s.config.ExcludeExtensions, ext)
+}
+
+// extensionFilteredEvent reports whether the watcher drops event, a Create or Write event of a file left out by
+// IncludeExtensions or ExcludeExtensions. Removals, and the events of directories, are kept.
+func (s *SFTP) extensionFilteredEvent(event fsnotify.Event) bool {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 || !s.extensionFiltered(event.Name) {
+		return false
+	}
+	info, err := os.Stat(event.Name)
+	return err != nil || !info.IsDir()
+}
+
+// hasExtension reports whether ext, as returned by filepath.Ext, is one of exts, which may omit the leading dot.
+// Extensions are compared case-insensitively.
+func hasExtension(exts []string, ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, e := range exts {
+		if strings.EqualFold("."+strings.TrimPrefix(e, "."), ext) {
+			return true
+		}
+	}
+	return false
+}
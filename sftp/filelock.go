@@ -0,0 +1,18 @@
+package sftp
+
+import "sync"
+
+// lockFile locks the mutex of remotePath, so only one upload of each remote file runs at a time while uploads of
+// different files proceed in parallel.
+//
+// Parameters:
+//   - remotePath: The path of the remote file being uploaded.
+//
+// Returns:
+//   - func(): The function unlocking the mutex once the upload is done.
+func (s *SFTP) lockFile(remotePath string) func() {
+	value, _ := s.fileLocks.LoadOrStore(remotePath, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
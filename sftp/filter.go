@@ -0,0 +1,50 @@
+package sftp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FilterByEventType returns an ExtraConfig.EventFilter accepting the events that have any of ops, e.g.
+// FilterByEventType(fsnotify.Create, fsnotify.Write) to propagate changes but not removals.
+//
+// Parameters:
+//   - ops: The event types to accept.
+//
+// Returns:
+//   - func(fsnotify.Event) bool: The filter.
+func FilterByEventType(ops ...fsnotify.Op) func(fsnotify.Event) bool {
+	var mask fsnotify.Op
+	for _, op := range ops {
+		mask |= op
+	}
+	return func(event fsnotify.Event) bool {
+		return event.Op&mask != 0
+	}
+}
+
+// FilterByExtension returns an ExtraConfig.EventFilter accepting the events for files with any of exts as extension,
+// compared case-insensitively.
+//
+// Parameters:
+//   - exts: The extensions to accept, with or without the leading dot, e.g. ".csv" or "csv".
+//
+// Returns:
+//   - func(fsnotify.Event) bool: The filter.
+func FilterByExtension(exts ...string) func(fsnotify.Event) bool {
+	accepted := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		accepted["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return func(event fsnotify.Event) bool {
+		return accepted[strings.ToLower(filepath.Ext(event.Name))]
+	}
+}
+
+// acceptEvent reports whether an event reported by the fsnotify watcher passes the EventFilter of the config.
+// All events pass when no filter is set.
+func (s *SFTP) acceptEvent(event fsnotify.Event) bool {
+	return s.config.EventFilter == nil || s.config.EventFilter(event)
+}
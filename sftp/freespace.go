@@ -0,0 +1,50 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pkg/sftp"
+)
+
+// ErrInsufficientSpace is returned by an upload, with CheckFreeSpace, when the remote file system has less free
+// space than the file needs.
+var ErrInsufficientSpace = errors.New("sftp: insufficient free space on the remote server")
+
+// remoteFreeSpace returns the space available to the user in the remote file system holding dir, with the
+// statvfs@openssh.com extension.
+//
+// It is a variable so tests can simulate a full remote file system.
+var remoteFreeSpace = func(client *sftp.Client, dir string) (uint64, error) {
+	stat, err := client.StatVFS(dir)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Frsize * stat.Bavail, nil
+}
+
+// checkFreeSpace fails an upload of size bytes into dir before it starts if CheckFreeSpace is set and the remote
+// file system does not have the space for it, rather than leaving a partial file once the disk is full. The check
+// is skipped when the server does not support the statvfs@openssh.com extension.
+//
+// Parameters:
+//   - client: The client of the upload.
+//   - dir: The remote directory the file is uploaded to.
+//   - size: The size of the file.
+//
+// Returns:
+//   - error: ErrInsufficientSpace if the file does not fit.
+func (s *SFTP) checkFreeSpace(client *sftp.Client, dir string, size int64) error {
+	if !s.config.CheckFreeSpace || size <= 0 {
+		return nil
+	}
+	free, err := remoteFreeSpace(client, dir)
+	if err != nil {
+		logger.Debug("Cannot check the free space of the remote server:", err)
+		return nil
+	}
+	if uint64(size) > free {
+		return fmt.Errorf("%w: %s needs %d bytes, %d are free", ErrInsufficientSpace, dir, size, free)
+	}
+	return nil
+}
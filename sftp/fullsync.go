@@ -0,0 +1,152 @@
+package sftp
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// TriggerFullSync re-walks LocalDir and RemoteDir and reconciles them in the current direction, e.g. after a problem
+// on the server was fixed, without restarting WatchDirectory. Unlike the initial sync, which by default only transfers
+// the missing files, it compares every file:
+//   - a source file missing on the destination, of a different size, or modified after its destination copy is
+//     transferred;
+//   - a destination file missing from the source is removed.
+//
+// Ignored files (TempFilePatterns, hidden files without SyncHidden) are left alone on both sides.
+//
+// It may run while the workers process changes. The destination is walked before the source, so a file created
+// during the full sync is never removed, and uploads take the per-file lock of the workers, so a file is never
+// written by both at once. Files the workers have already brought up to date are not transferred again.
+// Concurrent calls are serialized.
+//
+// Returns:
+//   - error: If a tree cannot be walked, or the errors of the files that could not be reconciled, joined with errors.Join.
+func (s *SFTP) TriggerFullSync() error {
+	s.fullSyncMu.Lock()
+	defer s.fullSyncMu.Unlock()
+	logger.Info("Running a full sync...")
+
+	direction := s.Direction()
+	localFiles := make(map[string]os.FileInfo)
+	remoteFiles := make(map[string]os.FileInfo)
+	var err error
+	if direction == RemoteToLocal {
+		err = walkLocalDir(s.config.LocalDir, localFiles)
+		if err == nil {
+			err = s.walkRemoteDir(s.config.RemoteDir, remoteFiles)
+		}
+	} else {
+		err = s.walkRemoteDir(s.config.RemoteDir, remoteFiles)
+		if err == nil {
+			err = walkLocalDir(s.config.LocalDir, localFiles)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// The remote files by the local path they mirror.
+	remoteByLocal := make(map[string]os.FileInfo, len(remoteFiles))
+	remotePaths := make(map[string]string, len(remoteFiles))
+	for remotePath, info := range remoteFiles {
+		localPath, err := s.localPath(remotePath)
+		if err != nil {
+			continue
+		}
+		remoteByLocal[localPath] = info
+		remotePaths[localPath] = remotePath
+	}
+
+	var errs []error
+	if direction == RemoteToLocal {
+		for localPath, info := range remoteByLocal {
+			if !s.ignored(remotePaths[localPath]) && outdated(info, localFiles[localPath]) {
+				errs = append(errs, s.downloadFile(remotePaths[localPath]))
+			}
+		}
+		for localPath := range localFiles {
+			if _, ok := remoteByLocal[localPath]; !ok && !s.ignored(localPath) {
+				logger.Info("Removing local file missing from the remote:", localPath)
+				errs = append(errs, ignoreNotExist(os.Remove(localPath)))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for localPath, info := range localFiles {
+		if !s.ignored(localPath) && outdated(info, remoteByLocal[localPath]) {
+			errs = append(errs, s.uploadFile(localPath))
+		}
+	}
+	for localPath := range remoteByLocal {
+		if _, ok := localFiles[localPath]; !ok && !s.ignored(localPath) {
+			logger.Info("Removing remote file missing locally:", remotePaths[localPath])
+			errs = append(errs, ignoreNotExist(s.RemoveRemoteFile(localPath)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TriggerFullSyncOnSignal runs TriggerFullSync whenever the process receives one of signals, SIGHUP if none is
+// given, so a daemon can be told to resync with kill -HUP. Errors of the full sync are logged.
+//
+// Parameters:
+//   - signals: The signals triggering a full sync.
+//
+// Returns:
+//   - func(): The function that stops listening for the signals. Listening also stops when the SFTP is closed.
+func (s *SFTP) TriggerFullSyncOnSignal(signals ...os.Signal) func() {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				logger.Info("Received", sig)
+				err := s.TriggerFullSync()
+				if err != nil {
+					logger.Error("Error running the full sync:", err)
+				}
+			case <-done:
+				return
+			case <-s.ctx.Done():
+				stop()
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// outdated reports whether the destination copy of a source file must be transferred again: it is missing, its size
+// differs, or the source was modified after it. SFTP reports modification times in whole seconds, so they are
+// compared at that precision.
+func outdated(source, destination os.FileInfo) bool {
+	if destination == nil || source.Size() != destination.Size() {
+		return true
+	}
+	return source.ModTime().Truncate(time.Second).After(destination.ModTime().Truncate(time.Second))
+}
+
+// ignoreNotExist returns nil for an error reporting that the file does not exist, e.g. because it was removed
+// meanwhile, and err otherwise.
+func ignoreNotExist(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package sftp
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isHidden reports whether SyncHidden is not set and a component of path below LocalDir or RemoteDir, or of a relative
+// path, begins with a dot, like .git or .DS_Store.
+func (s *SFTP) isHidden(path string) bool {
+	if s.config.SyncHidden {
+		return false
+	}
+	for _, root := range []string{s.config.LocalDir, s.config.RemoteDir} {
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(relativePath, "..") {
+			continue
+		}
+		for _, part := range strings.Split(relativePath, string(filepath.Separator)) {
+			if isHiddenName(part) {
+				return true
+			}
+		}
+		return false
+	}
+	if filepath.IsAbs(path) {
+		return isHiddenName(filepath.Base(path))
+	}
+	for _, part := range strings.Split(filepath.Clean(path), string(filepath.Separator)) {
+		if isHiddenName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHiddenName reports whether a file name begins with a dot.
+func isHiddenName(name string) bool {
+	return len(name) > 1 && name[0] == '.' && name != ".."
+}
+
+// ignored reports whether the file at path is never synced, being a temporary or, without SyncHidden, a hidden file,
+// or, with ConflictBackups, a conflict backup.
+func (s *SFTP) ignored(path string) bool {
+	return s.isTempFile(path) || s.isHidden(path) || s.isConflictBackup(path)
+}
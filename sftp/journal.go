@@ -0,0 +1,94 @@
+package sftp
+
+import (
+	"errors"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// errNoJournal is returned by ReplayJournal when JournalPath is not set.
+var errNoJournal = errors.New("sftp: no journal configured")
+
+// openJournal opens the journal at config.JournalPath, or returns nil if no path is configured.
+func openJournal(config *ExtraConfig) (*worker.Journal, error) {
+	if config == nil || config.JournalPath == "" {
+		return nil, nil
+	}
+	return worker.OpenJournal(config.JournalPath)
+}
+
+// ReplayJournal queues the tasks that were recorded in the journal but never completed, e.g. because
+// the process stopped or the connection was lost while they were pending.
+// Tasks that already failed MaxRetries times are not queued again; they stay in the journal for inspection.
+//
+// Returns:
+//   - error: If JournalPath is not set.
+//
+// Note: The workers must be running, e.g. through WatchDirectory, for the replayed tasks to be processed.
+func (s *SFTP) ReplayJournal() error {
+	if s.journal == nil {
+		return errNoJournal
+	}
+	pending := s.journal.Pending()
+	if len(pending) > 0 {
+		logger.Infof("Replaying %d pending tasks from the journal", len(pending))
+	}
+	for _, task := range pending {
+		if task.RetryCount >= s.config.MaxRetries {
+			logger.Errorf("Giving up on %s after %d failed attempts", task.Name, task.RetryCount)
+			continue
+		}
+		s.enqueue(task)
+	}
+	return nil
+}
+
+// journalAdd records task in the journal, if any.
+func (s *SFTP) journalAdd(task worker.Task) {
+	if s.journal == nil {
+		return
+	}
+	err := s.journal.Add(task)
+	if err != nil {
+		logger.Error("Error writing journal:", err)
+	}
+}
+
+// journalFailed records in the journal, if any, that processing task failed, see worker.Journal.Failed.
+func (s *SFTP) journalFailed(task worker.Task) {
+	if s.journal == nil {
+		return
+	}
+	err := s.journal.Failed(task)
+	if err != nil {
+		logger.Error("Error writing journal:", err)
+	}
+}
+
+// retryTask queues task, which failed with err, again with its RetryCount incremented, until it failed MaxRetries
+// times. Tasks failing with an error RetryClassifier finds permanent are not queued again. A task that is not queued
+// again is dropped and left in the journal, if any, with its failed attempt recorded.
+func (s *SFTP) retryTask(task worker.Task, err error) {
+	s.journalFailed(task)
+	if !s.retryable(err) {
+		logger.Errorf("Giving up on %s after a permanent error: %v", task.Name, err)
+		return
+	}
+	if task.RetryCount+1 >= s.config.MaxRetries {
+		logger.Errorf("Giving up on %s after %d failed attempts", task.Name, task.RetryCount+1)
+		return
+	}
+	s.pending.add(task, s.clock().Now())
+	s.Pool.Retry(task)
+}
+
+// journalDone removes the completed task from the journal, if any.
+func (s *SFTP) journalDone(task worker.Task) {
+	if s.journal == nil {
+		return
+	}
+	err := s.journal.Done(task)
+	if err != nil {
+		logger.Error("Error writing journal:", err)
+	}
+}
@@ -0,0 +1,89 @@
+package sftp
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// LogLevel is the minimum severity of the messages logged by the package.
+type LogLevel int32
+
+const (
+	//Debug logs every event, task and file operation
+	Debug LogLevel = iota
+	//Info logs the start and end of syncs and watches, completed transfers, retries and errors
+	Info
+	//Warn logs retries and errors
+	Warn
+	//Error logs failed operations only
+	Error
+)
+
+// logLevel is the current LogLevel, Debug by default so everything is logged.
+var logLevel = int32(Debug)
+
+// SetLogLevel sets the minimum severity of the messages logged by the package. It is safe to call at any time.
+func SetLogLevel(l LogLevel) {
+	atomic.StoreInt32(&logLevel, int32(l))
+}
+
+// levelLogger wraps a *log.Logger and drops the messages below the current LogLevel.
+// The methods without an f suffix format their arguments like log.Println.
+type levelLogger struct {
+	logger *log.Logger
+}
+
+func (l levelLogger) output(level LogLevel, s string) {
+	if level < LogLevel(atomic.LoadInt32(&logLevel)) {
+		return
+	}
+	// Skip output and the levelLogger method so Lshortfile reports the call site.
+	_ = l.logger.Output(3, s)
+}
+
+// Debug logs an event, task or file operation at the Debug level.
+func (l levelLogger) Debug(v ...interface{}) {
+	l.output(Debug, fmt.Sprintln(v...))
+}
+
+// Debugf logs an event, task or file operation at the Debug level, formatted like log.Printf.
+func (l levelLogger) Debugf(format string, v ...interface{}) {
+	l.output(Debug, fmt.Sprintf(format, v...))
+}
+
+// Info logs the start or end of a sync or watch, or a completed transfer, at the Info level.
+func (l levelLogger) Info(v ...interface{}) {
+	l.output(Info, fmt.Sprintln(v...))
+}
+
+// Infof logs the start or end of a sync or watch, or a completed transfer, at the Info level, formatted like
+// log.Printf.
+func (l levelLogger) Infof(format string, v ...interface{}) {
+	l.output(Info, fmt.Sprintf(format, v...))
+}
+
+// Warn logs a retry or recoverable problem at the Warn level.
+func (l levelLogger) Warn(v ...interface{}) {
+	l.output(Warn, fmt.Sprintln(v...))
+}
+
+// Warnf logs a retry or recoverable problem at the Warn level, formatted like log.Printf.
+func (l levelLogger) Warnf(format string, v ...interface{}) {
+	l.output(Warn, fmt.Sprintf(format, v...))
+}
+
+// Error logs a failed operation at the Error level.
+func (l levelLogger) Error(v ...interface{}) {
+	l.output(Error, fmt.Sprintln(v...))
+}
+
+// Errorf logs a failed operation at the Error level, formatted like log.Printf.
+func (l levelLogger) Errorf(format string, v ...interface{}) {
+	l.output(Error, fmt.Sprintf(format, v...))
+}
+
+// Fatal logs its arguments whatever the LogLevel and exits, like log.Fatal.
+func (l levelLogger) Fatal(v ...interface{}) {
+	l.logger.Fatal(v...)
+}
@@ -0,0 +1,192 @@
+package sftp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifest is the state of the source tree after the last successful initial sync: LocalDir for LocalToRemote,
+// RemoteDir for RemoteToLocal. It is stored as JSON in ManifestPath.
+type manifest struct {
+	//Files maps the slash-separated path of each file, relative to the source directory, to its state
+	Files map[string]manifestEntry `json:"files"`
+}
+
+// manifestEntry is the state of a file recorded in the manifest.
+type manifestEntry struct {
+	//Size is the size of the file in bytes
+	Size int64 `json:"size"`
+	//ModTime is the modification time of the file on the source side
+	ModTime time.Time `json:"mtime"`
+	//SHA256 is the hex SHA-256 checksum of the local copy of the file, empty if there was none
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// loadManifest reads the manifest stored in path.
+//
+// Returns:
+//   - *manifest: The manifest, or nil if path does not exist yet.
+//   - error: If the file cannot be read or parsed.
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// save stores the manifest in path. The file is replaced atomically, so an interrupted write leaves the
+// previous manifest in place.
+func (m *manifest) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// manifestState looks up a source file in the manifest loaded by the running initial sync.
+//
+// Parameters:
+//   - sourcePath: The path of the file below the source directory.
+//   - size: The current size of the file.
+//   - modTime: The current modification time of the file.
+//
+// Returns:
+//   - known: True if the manifest has an entry for the file.
+//   - unchanged: True if the entry has the same size and modification time, so the file was not changed since.
+func (s *SFTP) manifestState(sourcePath string, size int64, modTime time.Time) (known, unchanged bool) {
+	if s.syncManifest == nil {
+		return false, false
+	}
+	relativePath, err := filepath.Rel(s.sourceDir(), sourcePath)
+	if err != nil {
+		return false, false
+	}
+	entry, ok := s.syncManifest.Files[filepath.ToSlash(relativePath)]
+	if !ok {
+		return false, false
+	}
+	return true, entry.Size == size && entry.ModTime.Equal(modTime)
+}
+
+// sourceDir returns the directory the sync copies from: LocalDir for LocalToRemote, RemoteDir for RemoteToLocal.
+func (s *SFTP) sourceDir() string {
+	if s.Direction() == RemoteToLocal {
+		return s.config.RemoteDir
+	}
+	return s.config.LocalDir
+}
+
+// updateManifest records the source tree in ManifestPath after a successful initial sync. Files listed in previous
+// but gone from the source were deleted while the process was down, so they are removed from the destination.
+//
+// Checksums are computed from the local copies, and reused from previous for files whose size and modification
+// time did not change.
+//
+// Parameters:
+//   - previous: The manifest loaded before the sync, or nil.
+//
+// Returns:
+//   - error: If the source tree cannot be walked or the manifest cannot be stored.
+func (s *SFTP) updateManifest(previous *manifest) error {
+	files := make(map[string]os.FileInfo)
+	var err error
+	direction := s.Direction()
+	if direction == RemoteToLocal {
+		err = s.walkRemoteDir(s.config.RemoteDir, files)
+	} else {
+		err = walkLocalDir(s.config.LocalDir, files)
+	}
+	if err != nil {
+		return err
+	}
+
+	current := &manifest{Files: make(map[string]manifestEntry, len(files))}
+	for path, info := range files {
+		if s.ignored(path) {
+			continue
+		}
+		relativePath, err := filepath.Rel(s.sourceDir(), path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativePath)
+		entry := manifestEntry{Size: info.Size(), ModTime: info.ModTime()}
+		if old, ok := previous.entry(key); ok && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) && old.SHA256 != "" {
+			entry.SHA256 = old.SHA256
+		} else if sum, err := fileSHA256(filepath.Join(s.config.LocalDir, relativePath)); err == nil {
+			entry.SHA256 = sum
+		}
+		current.Files[key] = entry
+	}
+
+	if previous != nil {
+		for key := range previous.Files {
+			if _, ok := current.Files[key]; ok {
+				continue
+			}
+			err := s.removeDeleted(key, direction)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Error("Error removing file deleted while stopped:", err)
+			}
+		}
+	}
+	return current.save(s.config.ManifestPath)
+}
+
+// removeDeleted removes from the destination the file at relativePath, a slash-separated path from the manifest.
+func (s *SFTP) removeDeleted(relativePath string, direction SyncDirection) error {
+	logger.Info("Removing file deleted while stopped:", relativePath)
+	if direction == RemoteToLocal {
+		localPath, err := safeJoin(s.config.LocalDir, filepath.FromSlash(relativePath))
+		if err != nil {
+			return err
+		}
+		return os.Remove(localPath)
+	}
+	return s.RemoveRemoteFile(filepath.Join(s.config.LocalDir, filepath.FromSlash(relativePath)))
+}
+
+// entry returns the entry of the manifest for key, if m is not nil.
+func (m *manifest) entry(key string) (manifestEntry, bool) {
+	if m == nil {
+		return manifestEntry{}, false
+	}
+	entry, ok := m.Files[key]
+	return entry, ok
+}
+
+// fileSHA256 returns the hex SHA-256 checksum of the content of the local file path.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,101 @@
+package sftp
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+const (
+	//statusFailure is the SSH_FX_FAILURE status code, which OpenSSH returns for a directory that already exists
+	statusFailure = 4
+	//statusFileAlreadyExists is the SSH_FX_FILE_ALREADY_EXISTS status code of servers speaking SFTP version 5 or later
+	statusFileAlreadyExists = 11
+)
+
+// MkdirAllRemote creates a directory on the remote server, along with any missing parents.
+//
+// Parameters:
+//   - remotePath: The path of the directory. An absolute path (starting with /) is used as is; a relative path is
+//     relative to RemoteDir.
+//
+// Returns:
+//   - error: If a component of the path cannot be created, or exists and is not a directory.
+func (s *SFTP) MkdirAllRemote(remotePath string) error {
+	remotePath = filepath.ToSlash(remotePath)
+	if !strings.HasPrefix(remotePath, "/") {
+		remotePath = path.Join(filepath.ToSlash(s.config.RemoteDir), remotePath)
+	}
+	return mkdirAllRemote(s.Client, remotePath, s.config.DirMode)
+}
+
+// EnsureRemoteDir creates a directory below RemoteDir along with any missing parents, for scripts that need a remote
+// tree to exist before transferring, independently of the sync. It is idempotent: a directory that already exists is
+// left as it is. Created directories get DirMode, if set, and are remembered like the parent directories of uploads,
+// so they are not created again.
+//
+// Parameters:
+//   - relPath: The path of the directory relative to RemoteDir, with either separator.
+//
+// Returns:
+//   - error: ErrPathTraversal if relPath resolves outside RemoteDir, or an error if a component of the path cannot be
+//     created, or exists and is not a directory.
+func (s *SFTP) EnsureRemoteDir(relPath string) error {
+	remotePath, err := safeRemoteJoin(s.config.RemoteDir, filepath.ToSlash(relPath))
+	if err != nil {
+		return err
+	}
+	return s.dirs.ensure(remotePath, func(dir string) error { return mkdirAllRemote(s.Client, dir, s.config.DirMode) })
+}
+
+// mkdirAllRemote creates each component of remotePath in sequence with client. The components that already exist are
+// recognized from the status code returned by the server, so the usual case of a new directory below existing ones
+// takes no extra round trips. The components created are set to mode, unless it is zero.
+func mkdirAllRemote(client *sftp.Client, remotePath string, mode os.FileMode) error {
+	remotePath = path.Clean(filepath.ToSlash(remotePath))
+	current := ""
+	if strings.HasPrefix(remotePath, "/") {
+		current = "/"
+	}
+	for _, component := range strings.Split(strings.Trim(remotePath, "/"), "/") {
+		if component == "" || component == "." {
+			continue
+		}
+		current = path.Join(current, component)
+		err := client.Mkdir(current)
+		if err != nil {
+			if !remoteDirExists(client, current, err) {
+				return err
+			}
+			continue
+		}
+		if mode != 0 {
+			err = client.Chmod(current, mode.Perm())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remoteDirExists reports whether the error returned by Mkdir for dir means that dir already exists as a directory.
+// SFTP version 3 servers report it with the generic failure code, so that case is confirmed with Lstat.
+func remoteDirExists(client *sftp.Client, dir string, err error) bool {
+	var statusErr *sftp.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.Code {
+	case statusFileAlreadyExists:
+		return true
+	case statusFailure:
+		info, err := client.Lstat(dir)
+		return err == nil && info.IsDir()
+	}
+	return false
+}
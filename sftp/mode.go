@@ -0,0 +1,25 @@
+package sftp
+
+import "os"
+
+// applyRemoteMode gives a downloaded file the permissions of the remote file when PreserveRemoteMode is set, or
+// DefaultFileMode, if set, when the server did not report them. Errors are logged.
+//
+// Parameters:
+//   - file: The downloaded local file.
+//   - mode: The mode of the remote file, or 0 if it is unknown.
+func (s *SFTP) applyRemoteMode(file *os.File, mode os.FileMode) {
+	if !s.config.PreserveRemoteMode {
+		return
+	}
+	mode = mode.Perm()
+	if mode == 0 {
+		if s.config.DefaultFileMode == 0 {
+			return
+		}
+		mode = s.config.DefaultFileMode
+	}
+	if err := file.Chmod(mode); err != nil {
+		logger.Error("Error setting file mode:", err)
+	}
+}
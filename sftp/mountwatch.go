@@ -0,0 +1,82 @@
+package sftp
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchesMount reports whether remote changes are detected by watching RemoteDir with fsnotify instead of polling,
+// because UseLocalWatcher says the remote directory is mounted locally.
+func (s *SFTP) watchesMount() bool {
+	return s.config.UseLocalWatcher && s.Direction() == RemoteToLocal
+}
+
+// addWatchDirs adds rootDir and its subdirectories, except hidden ones without SyncHidden, to the fsnotify watcher.
+//
+// Parameters:
+//   - watcher: The fsnotify.Watcher to which the directories should be added.
+//   - rootDir: The root directory to start watching.
+//
+// Returns:
+//   - error: If a directory cannot be walked or watched.
+func (s *SFTP) addWatchDirs(watcher *fsnotify.Watcher, rootDir string) error {
+	return filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != rootDir && s.isHidden(path) {
+				return filepath.SkipDir
+			}
+			err = watcher.Add(path)
+			if err != nil {
+				return err
+			}
+			logger.Debug("Adding watcher to directory:", path)
+		}
+		return nil
+	})
+}
+
+// mountTask turns an fsnotify event for the mounted RemoteDir into the task the RemoteToLocal worker processes:
+// Create and Write events download the file and Remove and Rename events remove its local copy, the way the tasks
+// of pollRemoteDir do. A directory created under the mount is watched, and its files are queued since they may have
+// been written before the watch was added.
+//
+// Parameters:
+//   - watcher: The fsnotify.Watcher watching the mount.
+//   - event: The event reported by the watcher.
+//   - push: Queues the tasks for the files of a created directory.
+//
+// Returns:
+//   - worker.Task: The task for the event.
+//   - bool: False if the event calls for no task of its own.
+func (s *SFTP) mountTask(watcher *fsnotify.Watcher, event fsnotify.Event, push func(worker.Task)) (worker.Task, bool) {
+	switch {
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		return worker.Task{EventType: fsnotify.Remove, Name: event.Name, Priority: s.config.EventPriority}, true
+	case event.Op&(fsnotify.Create|fsnotify.Write) == 0:
+		return worker.Task{}, false
+	}
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		return worker.Task{}, false
+	}
+	if !info.IsDir() {
+		return worker.Task{EventType: fsnotify.Create, Name: event.Name, Priority: s.config.EventPriority}, true
+	}
+	if err := s.addWatchDirs(watcher, event.Name); err != nil {
+		logger.Error("Error watching directory:", err)
+	}
+	files := make(map[string]os.FileInfo)
+	if err := walkLocalDir(event.Name, files); err != nil {
+		logger.Error("Error listing directory:", err)
+	}
+	for path := range files {
+		push(worker.Task{EventType: fsnotify.Create, Name: path})
+	}
+	return worker.Task{}, false
+}
@@ -0,0 +1,228 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Host is a remote SFTP server MultiSync replicates to.
+type Host struct {
+	//Address is the IP address or hostname of the server
+	Address string
+	//Port is the port of the server
+	Port int
+	//Config is the configuration of the connection; every host must use the same LocalDir
+	Config *ExtraConfig
+}
+
+// HostStatus reports the replication results of one host.
+type HostStatus struct {
+	//Host is the address:port of the host
+	Host string
+	//Succeeded is the number of changes replicated to the host
+	Succeeded int64
+	//Failed is the number of changes that could not be replicated to the host
+	Failed int64
+	//LastError is the error of the last failed change, if any
+	LastError error
+}
+
+// MultiSync replicates a local directory to several SFTP servers, e.g. CDN edge nodes.
+// A single fsnotify watcher is shared and every change is applied to all hosts concurrently.
+type MultiSync struct {
+	localDir string
+	targets  []*multiTarget
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// multiTarget is the connection to one host of a MultiSync and the queue of changes to apply to it.
+type multiTarget struct {
+	host  string
+	sftp  *SFTP
+	tasks chan worker.Task
+	mu    sync.Mutex
+	stat  HostStatus
+}
+
+// NewMultiSync connects to every host in LocalToRemote mode. If any connection fails, the ones already
+// established are closed and the error is returned.
+//
+// Parameters:
+//   - hosts: The servers to replicate to. They must all share the same LocalDir.
+//
+// Returns:
+//   - *MultiSync: The replication to all hosts.
+//   - error: If the hosts are invalid or a connection cannot be established.
+//
+// Example:
+//
+//	multi, err := NewMultiSync([]Host{
+//	  {Address: "edge1.example.com", Port: 22, Config: config1},
+//	  {Address: "edge2.example.com", Port: 22, Config: config2},
+//	})
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	defer multi.Close()
+//	go multi.WatchDirectory()
+func NewMultiSync(hosts []Host) (*MultiSync, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("sftp: no hosts to replicate to")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &MultiSync{localDir: hosts[0].Config.LocalDir, ctx: ctx, cancel: cancel}
+	for _, host := range hosts {
+		if host.Config.LocalDir != m.localDir {
+			_ = m.Close()
+			return nil, fmt.Errorf("sftp: host %s:%d uses local directory %s instead of %s", host.Address, host.Port, host.Config.LocalDir, m.localDir)
+		}
+		s, err := Connect(host.Address, host.Port, LocalToRemote, host.Config)
+		if err != nil {
+			_ = m.Close()
+			return nil, fmt.Errorf("%s:%d: %w", host.Address, host.Port, err)
+		}
+		s.ctx = ctx
+		name := fmt.Sprintf("%s:%d", host.Address, host.Port)
+		m.targets = append(m.targets, &multiTarget{
+			host:  name,
+			sftp:  s,
+			tasks: make(chan worker.Task, cap(s.Pool.Tasks)),
+			stat:  HostStatus{Host: name},
+		})
+	}
+	return m, nil
+}
+
+// Sync runs the initial sync of every host concurrently.
+//
+// Returns:
+//   - error: The errors of the hosts that failed, each prefixed with the host.
+func (m *MultiSync) Sync() error {
+	errs := make([]error, len(m.targets))
+	var wg sync.WaitGroup
+	for i, target := range m.targets {
+		wg.Add(1)
+		go func(i int, target *multiTarget) {
+			defer wg.Done()
+			err := target.sftp.initialSync()
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", target.host, err)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// WatchDirectory watches the local directory and replicates every change to all hosts. Each host applies
+// the changes in the order they were received, independently of the other hosts.
+// It blocks until Close is called.
+//
+// Returns:
+//   - error: If the local directory cannot be watched.
+func (m *MultiSync) WatchDirectory() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+	err = filepath.Walk(m.localDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, target := range m.targets {
+		go m.apply(target)
+	}
+	for {
+		select {
+		case <-m.ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			m.dispatch(worker.Task{EventType: event.Op, Name: event.Name})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error("Error:", err)
+		}
+	}
+}
+
+// dispatch queues task for every host.
+func (m *MultiSync) dispatch(task worker.Task) {
+	for _, target := range m.targets {
+		if target.sftp.ignored(task.Name) {
+			continue
+		}
+		target.tasks <- task
+	}
+}
+
+// apply applies the changes queued for target until the MultiSync is closed, recording the results.
+func (m *MultiSync) apply(target *multiTarget) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case task := <-target.tasks:
+			err := target.sftp.processTask(0, task, LocalToRemote)
+			target.mu.Lock()
+			if err != nil {
+				target.stat.Failed++
+				target.stat.LastError = err
+			} else {
+				target.stat.Succeeded++
+			}
+			target.mu.Unlock()
+		}
+	}
+}
+
+// Status returns the replication results of every host, in the order the hosts were given.
+func (m *MultiSync) Status() []HostStatus {
+	status := make([]HostStatus, len(m.targets))
+	for i, target := range m.targets {
+		target.mu.Lock()
+		status[i] = target.stat
+		target.mu.Unlock()
+	}
+	return status
+}
+
+// Close stops watching and closes the connections to all hosts.
+//
+// Returns:
+//   - error: The errors of the connections that could not be closed, each prefixed with the host.
+func (m *MultiSync) Close() error {
+	m.cancel()
+	var errs []error
+	for _, target := range m.targets {
+		err := target.sftp.closeConns()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.host, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorPolicy selects what the initial sync does when a file or directory cannot be synced.
+type ErrorPolicy int
+
+const (
+	//OnErrorAbort stops the sync at the first error and returns it
+	OnErrorAbort ErrorPolicy = iota
+	//OnErrorContinue logs each error and goes on with the next entry
+	OnErrorContinue
+	//OnErrorCollect goes on with the next entry and returns all the errors, joined with errors.Join, at the end
+	OnErrorCollect
+)
+
+// abortSync applies the OnError policy to an entry syncDir failed to sync.
+//
+// Parameters:
+//   - path: The source path of the entry, for the messages.
+//   - err: The error syncing the entry.
+//
+// Returns:
+//   - bool: True if syncDir must stop and return err.
+func (s *SFTP) abortSync(path string, err error) bool {
+	if s.config.OnError == OnErrorAbort {
+		return true
+	}
+	if s.config.OnError == OnErrorContinue {
+		logger.Errorf("Error syncing %s: %v", path, err)
+	}
+	s.syncErrs = append(s.syncErrs, fmt.Errorf("%s: %w", path, err))
+	return false
+}
+
+// syncErrors returns the error the initial sync ends with for the entries abortSync let it skip.
+//
+// Returns:
+//   - error: The skipped errors joined with OnErrorCollect, otherwise nil.
+func (s *SFTP) syncErrors() error {
+	if len(s.syncErrs) == 0 {
+		return nil
+	}
+	if s.config.OnError == OnErrorCollect {
+		return errors.Join(s.syncErrs...)
+	}
+	logger.Warnf("Initial sync skipped %d entries after errors", len(s.syncErrs))
+	return nil
+}
@@ -0,0 +1,12 @@
+package sftp
+
+// Pause stops the workers from starting new transfers, e.g. during a maintenance window, once the transfers in
+// progress are done. The watcher keeps running and the changes it reports are queued until Resume.
+func (s *SFTP) Pause() {
+	s.Pool.Pause()
+}
+
+// Resume lets the workers process the queued changes again after Pause.
+func (s *SFTP) Resume() {
+	s.Pool.Resume()
+}
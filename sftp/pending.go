@@ -0,0 +1,103 @@
+package sftp
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// TaskInfo describes a task queued for the workers, see PendingTasks.
+type TaskInfo struct {
+	//Path is the path of the file of the task: a local path for LocalToRemote, a remote path for RemoteToLocal
+	Path string
+	//EventType is the file event the task processes
+	EventType fsnotify.Op
+	//Enqueued is when the task was queued
+	Enqueued time.Time
+}
+
+// pendingTasks tracks the tasks queued by enqueue until a worker takes them, so they can be listed and canceled.
+// The worker pool queues tasks in channels, which can be neither listed nor edited, so a canceled task stays queued
+// and the worker that takes it drops it.
+type pendingTasks struct {
+	mu    sync.Mutex
+	tasks []pendingTask
+}
+
+// pendingTask is a task tracked by pendingTasks.
+type pendingTask struct {
+	info     TaskInfo
+	canceled bool
+}
+
+// add tracks task, queued at now.
+func (p *pendingTasks) add(task worker.Task, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tasks = append(p.tasks, pendingTask{info: TaskInfo{Path: task.Name, EventType: task.EventType, Enqueued: now}})
+}
+
+// take stops tracking task, which a worker received, and reports whether it was canceled. Tasks for the same path
+// and event cannot be told apart, so the one queued first is taken.
+func (p *pendingTasks) take(task worker.Task) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, pending := range p.tasks {
+		if pending.info.Path == task.Name && pending.info.EventType == task.EventType {
+			p.tasks = append(p.tasks[:i], p.tasks[i+1:]...)
+			return pending.canceled
+		}
+	}
+	return false
+}
+
+// list returns the tasks that are neither taken nor canceled, in the order they were queued.
+func (p *pendingTasks) list() []TaskInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	infos := make([]TaskInfo, 0, len(p.tasks))
+	for _, pending := range p.tasks {
+		if !pending.canceled {
+			infos = append(infos, pending.info)
+		}
+	}
+	return infos
+}
+
+// cancel marks the tasks for path as canceled and reports whether there were any.
+func (p *pendingTasks) cancel(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	canceled := false
+	for i := range p.tasks {
+		if !p.tasks[i].canceled && filepath.Clean(p.tasks[i].info.Path) == path {
+			p.tasks[i].canceled = true
+			canceled = true
+		}
+	}
+	return canceled
+}
+
+// PendingTasks returns the tasks queued for the workers that no worker has started yet, in the order they were
+// queued, including those held back while a background initial sync runs.
+//
+// Returns:
+//   - []TaskInfo: The path, event type and enqueue time of each pending task.
+func (s *SFTP) PendingTasks() []TaskInfo {
+	return s.pending.list()
+}
+
+// CancelTask drops the pending tasks for path before a worker starts them, e.g. to keep a mistaken change from
+// propagating. Tasks already being processed are not affected.
+//
+// Parameters:
+//   - path: The path of the file, as reported by PendingTasks.
+//
+// Returns:
+//   - bool: True if a pending task was canceled.
+func (s *SFTP) CancelTask(path string) bool {
+	return s.pending.cancel(filepath.Clean(path))
+}
@@ -0,0 +1,29 @@
+package sftp
+
+import "context"
+
+// PingRemote checks that the server is reachable and the credentials are still accepted, e.g. before starting
+// WatchDirectory or in a health-check loop, by stating RemoteDir. Unlike an ICMP ping, it goes through the SSH and
+// SFTP layers of the connection.
+//
+// Parameters:
+//   - ctx: The context of the check. PingRemote returns ctx.Err() if it is done before the server answers.
+//
+// Returns:
+//   - error: If the server cannot be reached or RemoteDir cannot be stated, nil otherwise.
+func (s *SFTP) PingRemote(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.Client.Stat(s.config.RemoteDir)
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,254 @@
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchBackend selects how local changes are detected in LocalToRemote mode.
+type WatchBackend int
+
+const (
+	//WatchAuto uses fsnotify and falls back to polling when fsnotify fails or never delivers events
+	WatchAuto WatchBackend = iota
+	//WatchFSNotify always uses fsnotify
+	WatchFSNotify
+	//WatchPolling walks LocalDir every PollInterval and diffs successive snapshots
+	WatchPolling
+)
+
+// defaultPollInterval is used when ExtraConfig.PollInterval is not set.
+const defaultPollInterval = time.Second
+
+// probeFileName is the name of the file WatchAuto creates in LocalDir to check that fsnotify delivers events.
+const probeFileName = ".syncpkg-probe"
+
+// probeTimeout is how long WatchAuto waits for fsnotify to report the probe file.
+var probeTimeout = 2 * time.Second
+
+// fsnotifyDelivers reports whether fsnotify delivers events for dir. It creates a probe file in dir
+// and waits for the event dispatcher to signal on seen that the file was reported.
+//
+// It is a variable so tests can simulate file systems on which fsnotify stays silent.
+var fsnotifyDelivers = func(dir string, seen <-chan struct{}) bool {
+	probe := filepath.Join(dir, probeFileName)
+	file, err := os.Create(probe)
+	if err != nil {
+		return false
+	}
+	_ = file.Close()
+	defer func() {
+		_ = os.Remove(probe)
+	}()
+
+	select {
+	case <-seen:
+		return true
+	case <-time.After(probeTimeout):
+		return false
+	}
+}
+
+// pollInterval returns the configured PollInterval or defaultPollInterval when unset.
+func (s *SFTP) pollInterval() time.Duration {
+	if s.config.PollInterval > 0 {
+		return s.config.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// clock returns the configured Clock or worker.RealClock when unset.
+func (s *SFTP) clock() worker.Clock {
+	return worker.ClockOrReal(s.config.Clock)
+}
+
+// canPoll reports whether local changes may be detected by polling instead of fsnotify.
+func (s *SFTP) canPoll() bool {
+	return s.Direction() == LocalToRemote && s.config.WatchBackend != WatchFSNotify
+}
+
+// watchLocal starts watching LocalDir with the configured WatchBackend.
+//
+// Parameters:
+//   - ctx: The context that stops polling when canceled.
+//   - watcher: The fsnotify watcher, or nil if it could not be created.
+//   - watcherErr: The error returned when creating the watcher, if any.
+//   - seen: Signalled by the event dispatcher when the probe file is reported.
+//
+// Returns:
+//   - error: If the directories cannot be watched or polled.
+//
+// Note: When polling, the function blocks until ctx is canceled.
+func (s *SFTP) watchLocal(ctx context.Context, watcher *fsnotify.Watcher, watcherErr error, seen <-chan struct{}) error {
+	switch s.config.WatchBackend {
+	case WatchPolling:
+		return s.pollLocalDir(ctx, s.config.LocalDir)
+	case WatchFSNotify:
+		if watcherErr != nil {
+			return watcherErr
+		}
+		return s.AddDirectoriesToWatcher(watcher, s.config.LocalDir)
+	}
+
+	if watcherErr == nil {
+		watcherErr = s.AddDirectoriesToWatcher(watcher, s.config.LocalDir)
+	}
+	if watcherErr == nil && fsnotifyDelivers(s.config.LocalDir, seen) {
+		return nil
+	}
+	logger.Warn("fsnotify is not delivering events, falling back to polling:", watcherErr)
+	if watcher != nil {
+		_ = watcher.Close()
+	}
+	return s.pollLocalDir(ctx, s.config.LocalDir)
+}
+
+// pollLocalDir walks the local directory every PollInterval and compares the snapshot with the
+// previous one, queueing Create, Write and Remove tasks for new, modified and removed files.
+//
+// Parameters:
+//   - ctx: The context that stops polling when canceled.
+//   - rootDir: The local directory to poll.
+//
+// Returns:
+//   - error: If the local directory cannot be walked.
+//
+// Note: The function blocks until ctx is canceled.
+func (s *SFTP) pollLocalDir(ctx context.Context, rootDir string) error {
+	var prevFiles map[string]os.FileInfo
+	for {
+		newFiles := make(map[string]os.FileInfo)
+		err := walkLocalDir(rootDir, newFiles)
+		if err != nil {
+			return err
+		}
+
+		if prevFiles != nil {
+			for p, file := range newFiles {
+				if s.extensionFiltered(p) {
+					continue
+				}
+				prevFile, exists := prevFiles[p]
+				switch {
+				case !exists:
+					s.enqueue(worker.Task{EventType: fsnotify.Create, Name: p})
+					logger.Debug("New local file:", p)
+				case prevFile.ModTime().Before(file.ModTime()) || prevFile.Size() != file.Size():
+					s.enqueue(worker.Task{EventType: fsnotify.Write, Name: p})
+					logger.Debug("Modified local file:", p)
+				}
+			}
+			for p := range prevFiles {
+				if _, exists := newFiles[p]; !exists && !s.extensionFiltered(p) {
+					s.enqueue(worker.Task{EventType: fsnotify.Remove, Name: p})
+					logger.Debug("Local file removed:", p)
+				}
+			}
+		}
+		if prevFiles == nil {
+			markSeeded(ctx)
+		}
+		prevFiles = newFiles
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.clock().After(s.pollInterval()):
+		}
+	}
+}
+
+// pollRemoteDir dynamically monitors the remote directory and its subdirectories by comparing the
+// file modifications between successive walks every PollInterval, queueing Create tasks for new or
+// modified files and Remove tasks for removed files. With TreeHashMode, the files are only compared when the
+// treeHash of the walk differs from that of the previous one.
+//
+// Parameters:
+//   - ctx: The context that stops polling when canceled.
+//   - rootDir: The remote directory to poll.
+//
+// Returns:
+//   - error: If the remote directory cannot be walked.
+//
+// Note: The function blocks until ctx is canceled.
+func (s *SFTP) pollRemoteDir(ctx context.Context, rootDir string) error {
+	var prevFiles map[string]os.FileInfo
+	var prevHash [sha256.Size]byte
+	for {
+		// Read the remote directory and its subdirectories.
+		newFiles := make(map[string]os.FileInfo)
+		err := s.walkRemoteDir(rootDir, newFiles)
+		if err != nil {
+			return err
+		}
+		changed := true
+		if s.config.TreeHashMode {
+			hash := treeHash(newFiles)
+			changed = hash != prevHash
+			prevHash = hash
+		}
+
+		// Check for new or removed files.
+		if prevFiles != nil && !changed {
+			logger.Debug("Remote tree unchanged:", rootDir)
+		} else if prevFiles != nil {
+			for p, file := range newFiles {
+				if s.extensionFiltered(p) {
+					continue
+				}
+				prevFile, exists := prevFiles[p]
+				if !exists || prevFile.ModTime().Before(file.ModTime()) {
+					s.enqueue(worker.Task{EventType: fsnotify.Create, Name: p})
+					logger.Debug("New or modified file:", p)
+				}
+			}
+			for p := range prevFiles {
+				_, exists := newFiles[p]
+				if !exists && !s.extensionFiltered(p) {
+					s.enqueue(worker.Task{EventType: fsnotify.Remove, Name: p})
+					logger.Debug("File removed:", p)
+				}
+			}
+		}
+		if prevFiles == nil {
+			markSeeded(ctx)
+		}
+		prevFiles = newFiles
+
+		// Wait for a while before checking again.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.clock().After(s.pollInterval()):
+		}
+	}
+}
+
+// walkVisited is called by walkLocalDir for each path it visits, before looking at it.
+//
+// It is a variable so tests can change the tree while it is walked.
+var walkVisited = func(path string) {}
+
+// walkLocalDir traverses a local directory and its subdirectories and adds all regular files it finds to the provided map.
+// Files and directories removed while the walk lists their parent directory are skipped, not reported as errors.
+func walkLocalDir(dir string, files map[string]os.FileInfo) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		walkVisited(path)
+		if err != nil {
+			if os.IsNotExist(err) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files[path] = info
+		}
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package sftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// runPostUploadIfIdle runs the PostUploadCommand once the workers are idle and at least one file
+// was uploaded since the command last ran, so a batch of uploads triggers it only once.
+func (s *SFTP) runPostUploadIfIdle() {
+	if s.config.PostUploadCommand == "" {
+		return
+	}
+	if atomic.LoadInt64(&s.activeTasks) > 0 || s.Pool.Pending() > 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&s.uploaded, 1, 0) {
+		return
+	}
+	err := s.runPostUploadCommand()
+	if err != nil {
+		logger.Error("Error running post-upload command:", err)
+	}
+}
+
+// runPostUploadCommand runs the PostUploadCommand on the remote server in a new session of the SSH connection.
+//
+// Returns:
+//   - error: If the session cannot be started, the command exits with a non-zero status (the error includes its stderr)
+//     or it does not finish within PostUploadCommandTimeout.
+func (s *SFTP) runPostUploadCommand() error {
+	if s.sshConn == nil {
+		return errors.New("no ssh connection to run the post-upload command on")
+	}
+	session, err := s.sshConn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	command := s.config.PostUploadCommand
+	logger.Info("Running post-upload command:", command)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	var timeout <-chan time.Time
+	if s.config.PostUploadCommandTimeout > 0 {
+		timeout = s.clock().After(s.config.PostUploadCommandTimeout)
+	}
+	select {
+	case err = <-done:
+	case <-timeout:
+		return fmt.Errorf("post-upload command %q timed out after %s", command, s.config.PostUploadCommandTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("post-upload command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
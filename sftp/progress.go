@@ -0,0 +1,101 @@
+package sftp
+
+import (
+	"io"
+
+	"github.com/cploutarchou/syncpkg/internal/ctxio"
+)
+
+// ProgressEvent reports the progress of a single file transfer.
+type ProgressEvent struct {
+	//Filename is the local path of the file being transferred
+	Filename string
+	//BytesTransferred is the number of bytes transferred so far
+	BytesTransferred int64
+	//TotalBytes is the size of the file, or -1 if unknown
+	TotalBytes int64
+	//Done is true on the last event of a transfer, whether or not it succeeded
+	Done bool
+}
+
+// WithProgressChannel makes uploads and downloads report their progress on ch.
+// Events are sent without blocking, so events are dropped while ch is full rather than slowing down transfers.
+//
+// Parameters:
+//   - ch: The channel progress events are sent on, or nil to stop reporting progress.
+//
+// Returns:
+//   - *SFTP: The SFTP connection, to allow chaining.
+//
+// Note: The channel must be set before WatchDirectory is called.
+func (s *SFTP) WithProgressChannel(ch chan<- ProgressEvent) *SFTP {
+	s.progress = ch
+	return s
+}
+
+// NewProgressChannel creates a progress channel with a buffer of bufSize events, attaches it
+// with WithProgressChannel and returns it for reading.
+//
+// Example:
+//
+//	events := sftpConn.NewProgressChannel(100)
+//	go func() {
+//	  for event := range events {
+//	    fmt.Printf("%s: %d/%d\n", event.Filename, event.BytesTransferred, event.TotalBytes)
+//	  }
+//	}()
+func (s *SFTP) NewProgressChannel(bufSize int) <-chan ProgressEvent {
+	ch := make(chan ProgressEvent, bufSize)
+	s.WithProgressChannel(ch)
+	return ch
+}
+
+// sendProgress sends event on the progress channel without blocking.
+func (s *SFTP) sendProgress(event ProgressEvent) {
+	if s.progress == nil {
+		return
+	}
+	select {
+	case s.progress <- event:
+	default:
+	}
+}
+
+// copyWithProgress copies src to dst through a buffer of CopyBufferSize bytes and reports the progress
+// of the transfer of filename. The copy stops after the read in progress once the context of s is canceled, and then
+// returns its error, whatever the error the cancellation caused on dst or src.
+func (s *SFTP) copyWithProgress(dst io.Writer, src io.Reader, filename string, total int64) (int64, error) {
+	buf := s.getBuffer()
+	defer s.buffers.Put(buf)
+
+	// Hide io.ReaderFrom and io.WriterTo so io.CopyBuffer uses the configured buffer; ctxio.Reader hides the latter.
+	dst = struct{ io.Writer }{dst}
+	src = ctxio.Reader(s.ctx, src)
+	if s.progress != nil {
+		src = &progressReader{reader: src, report: func(n int64) {
+			s.sendProgress(ProgressEvent{Filename: filename, BytesTransferred: n, TotalBytes: total})
+		}}
+	}
+	n, err := io.CopyBuffer(dst, src, *buf)
+	if err != nil && s.ctx.Err() != nil {
+		err = s.ctx.Err()
+	}
+	s.sendProgress(ProgressEvent{Filename: filename, BytesTransferred: n, TotalBytes: total, Done: true})
+	return n, err
+}
+
+// progressReader calls report with the running byte count after every read.
+type progressReader struct {
+	reader io.Reader
+	read   int64
+	report func(n int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.report(r.read)
+	}
+	return n, err
+}
@@ -0,0 +1,83 @@
+package sftp
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// JumpHost is an SSH server (bastion) the connections to the SFTP server are tunnelled through.
+type JumpHost struct {
+	//Address is the IP address or hostname of the jump host
+	Address string
+	//Port is the SSH port of the jump host
+	Port int
+	//Username is the username to authenticate to the jump host with
+	Username string
+	//Password is the password to authenticate to the jump host with
+	Password string
+}
+
+// dialFunc opens a network connection to addr.
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// newDialer returns the function the SSH connections to the SFTP server are dialed with: directly, through the
+// SOCKS5 proxy of ProxyURL, through the JumpHost, or through the jump host reached via the proxy.
+//
+// Parameters:
+//   - config: The configuration holding ProxyURL and JumpHost.
+//
+// Returns:
+//   - dialFunc: The function dialing the SFTP server.
+//   - *ssh.Client: The SSH connection to the jump host, which the caller must close, or nil without JumpHost.
+//   - error: If ProxyURL is invalid, or if the proxy or the jump host cannot be reached.
+func newDialer(config *ExtraConfig) (dialFunc, *ssh.Client, error) {
+	dial := dialFunc(net.Dial)
+	if config.ProxyURL != "" {
+		u, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sftp: invalid ProxyURL: %w", err)
+		}
+		dialer, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sftp: invalid ProxyURL %s: %w", u.Redacted(), err)
+		}
+		dial = func(network, addr string) (net.Conn, error) {
+			conn, err := dialer.Dial(network, addr)
+			if err != nil {
+				return nil, fmt.Errorf("sftp: cannot reach %s through proxy %s: %w", addr, u.Redacted(), err)
+			}
+			return conn, nil
+		}
+	}
+	if config.JumpHost == nil {
+		return dial, nil, nil
+	}
+
+	jumpAddr := fmt.Sprintf("%s:%d", config.JumpHost.Address, config.JumpHost.Port)
+	conn, err := dial("tcp", jumpAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sftp: cannot reach jump host %s: %w", jumpAddr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, jumpAddr, &ssh.ClientConfig{
+		User:            config.JumpHost.Username,
+		Auth:            []ssh.AuthMethod{ssh.Password(config.JumpHost.Password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		ClientVersion:   config.ClientVersion,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("sftp: jump host %s: %w", jumpAddr, err)
+	}
+	bastion := ssh.NewClient(sshConn, chans, reqs)
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := bastion.Dial(network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: cannot reach %s through jump host %s: %w", addr, jumpAddr, err)
+		}
+		return conn, nil
+	}, bastion, nil
+}
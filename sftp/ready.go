@@ -0,0 +1,91 @@
+package sftp
+
+import (
+	"context"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// Ready returns a channel that is closed once the initial sync is complete and the directory is being watched.
+//
+// Example:
+//
+//	go sftpConn.WatchDirectory()
+//	<-sftpConn.Ready()
+//	log.Println("Watching for changes")
+func (s *SFTP) Ready() <-chan struct{} {
+	return s.readyChan()
+}
+
+// readyChan returns the channel returned by Ready, creating it on first use.
+func (s *SFTP) readyChan() chan struct{} {
+	s.readyInit.Do(func() {
+		s.ready = make(chan struct{})
+	})
+	return s.ready
+}
+
+// markReady closes the channel returned by Ready. It may be called more than once.
+func (s *SFTP) markReady() {
+	ready := s.readyChan()
+	s.readyDone.Do(func() {
+		close(ready)
+	})
+}
+
+// seededKey is the context key of the channel closed by markSeeded.
+type seededKey struct{}
+
+// withSeeded returns a copy of ctx carrying the channel closed by markSeeded.
+func withSeeded(ctx context.Context, seeded chan struct{}) context.Context {
+	return context.WithValue(ctx, seededKey{}, seeded)
+}
+
+// markSeeded signals that the watcher of the session of ctx is live: the fsnotify watcher has been set up,
+// or the poller has taken its first snapshot. It is only called from the goroutine running the watcher.
+func markSeeded(ctx context.Context) {
+	seeded, ok := ctx.Value(seededKey{}).(chan struct{})
+	if !ok {
+		return
+	}
+	select {
+	case <-seeded:
+	default:
+		close(seeded)
+	}
+}
+
+// enqueue queues a task for the workers, or holds it back while the initial sync runs in the background.
+func (s *SFTP) enqueue(task worker.Task) {
+	s.journalAdd(task)
+	s.pending.add(task, s.clock().Now())
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.holding {
+		s.held = append(s.held, task)
+		return
+	}
+	s.Pool.Submit(task)
+}
+
+// holdTasks makes enqueue hold tasks back until releaseTasks is called.
+func (s *SFTP) holdTasks() {
+	s.queueMu.Lock()
+	s.holding = true
+	s.queueMu.Unlock()
+}
+
+// releaseTasks queues the tasks held back since holdTasks in the order they were received
+// and stops holding new ones.
+func (s *SFTP) releaseTasks() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if len(s.held) > 0 {
+		logger.Infof("Replaying %d events received during the initial sync", len(s.held))
+	}
+	for _, task := range s.held {
+		s.Pool.Submit(task)
+	}
+	s.held = nil
+	s.holding = false
+}
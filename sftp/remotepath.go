@@ -0,0 +1,31 @@
+package sftp
+
+import (
+	"path"
+	"path/filepath"
+)
+
+// toRemotePath returns the remote counterpart of localFile, a path below localDir, in remoteDir. Remote paths always
+// use forward slashes, whatever the local separator: on Windows, filepath.Join would build paths such as
+// /remote/subdir\file.txt, which SFTP servers reject.
+//
+// Parameters:
+//   - localDir: The local directory localFile is relative to.
+//   - localFile: The local path to convert.
+//   - remoteDir: The remote directory mirroring localDir.
+//
+// Returns:
+//   - string: The slash-separated remote path. A localFile without a path relative to localDir, e.g. on another
+//     Windows volume, is mapped to its name in remoteDir.
+func toRemotePath(localDir, localFile, remoteDir string) string {
+	relativePath, err := filepath.Rel(localDir, localFile)
+	if err != nil {
+		relativePath = filepath.Base(localFile)
+	}
+	return remoteJoin(remoteDir, relativePath)
+}
+
+// remoteJoin joins the local relative path relativePath to the remote directory dir, with forward slashes.
+func remoteJoin(dir, relativePath string) string {
+	return path.Join(filepath.ToSlash(dir), filepath.ToSlash(relativePath))
+}
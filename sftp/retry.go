@@ -0,0 +1,46 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/pkg/sftp"
+)
+
+// IsTransient reports whether a failed task may succeed if it is attempted again. It is the default
+// ExtraConfig.RetryClassifier.
+//
+// Missing files, permission errors and canceled contexts are permanent, as are the SFTP statuses the server replies
+// with for them, for malformed requests and for unsupported operations. Other errors, such as lost connections,
+// timeouts and EOF, are transient.
+//
+// Parameters:
+//   - err: The error of the failed attempt.
+//
+// Returns:
+//   - bool: True if the task should be attempted again.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.FxCode() {
+		case sftp.ErrSSHFxNoSuchFile, sftp.ErrSSHFxPermissionDenied, sftp.ErrSSHFxBadMessage, sftp.ErrSSHFxOpUnsupported:
+			return false
+		}
+	}
+	return true
+}
+
+// retryable reports whether a failed task is attempted again, according to RetryClassifier or IsTransient.
+func (s *SFTP) retryable(err error) bool {
+	if s.config.RetryClassifier != nil {
+		return s.config.RetryClassifier(err)
+	}
+	return IsTransient(err)
+}
@@ -0,0 +1,63 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrSpecialFile is returned by a sync that finds a FIFO, socket or device file while SkipSpecialFiles is not set.
+// Such files cannot be represented on the other side, and opening a FIFO blocks until a writer opens it.
+var ErrSpecialFile = errors.New("sftp: not a regular file")
+
+// isSpecialMode reports whether mode is that of a FIFO, socket, device or other irregular file.
+func isSpecialMode(mode os.FileMode) bool {
+	return mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice|os.ModeIrregular) != 0
+}
+
+// skipSpecial reports whether the file at path must not be transferred because it is a special file. It is skipped
+// with a notice if SkipSpecialFiles is set, and reported with ErrSpecialFile otherwise.
+//
+// Parameters:
+//   - path: The path of the file, for the notice and the error.
+//   - mode: The mode of the file. The mode of a symlink is that of its target, which is a local file.
+//
+// Returns:
+//   - bool: True if the file must not be transferred.
+//   - error: ErrSpecialFile if the file is special and SkipSpecialFiles is not set.
+func (s *SFTP) skipSpecial(path string, mode os.FileMode) (bool, error) {
+	if mode&os.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, nil
+		}
+		mode = info.Mode()
+	}
+	if !isSpecialMode(mode) {
+		return false, nil
+	}
+	if !s.config.SkipSpecialFiles {
+		return true, fmt.Errorf("%w: %s", ErrSpecialFile, path)
+	}
+	logger.Info("Skipping special file:", path)
+	return true, nil
+}
+
+// specialEvent reports whether a Create or Write event of the fsnotify watcher is for a special file, whose event
+// is dropped since the file cannot be transferred.
+func (s *SFTP) specialEvent(event fsnotify.Event) bool {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return false
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return false
+	}
+	skip, err := s.skipSpecial(event.Name, info.Mode())
+	if err != nil {
+		logger.Error("Ignoring event:", err)
+	}
+	return skip
+}
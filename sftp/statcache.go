@@ -0,0 +1,105 @@
+package sftp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStatCacheTTL is used when ExtraConfig.StatCacheTTL is not set.
+const defaultStatCacheTTL = 2 * time.Second
+
+// statCache caches the os.FileInfo of remote paths for a short time, so repeated lookups of the same path
+// do not each cost a round trip to the server.
+type statCache struct {
+	mu      sync.Mutex
+	entries map[string]statEntry
+}
+
+// statEntry is a cached os.FileInfo and the time it expires at.
+type statEntry struct {
+	info    os.FileInfo
+	expires time.Time
+}
+
+// get returns the cached os.FileInfo of remotePath, if present and not expired.
+func (c *statCache) get(remotePath string) (os.FileInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[filepath.Clean(remotePath)]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.info, true
+}
+
+// put caches info for remotePath for ttl.
+func (c *statCache) put(remotePath string, info os.FileInfo, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]statEntry)
+	}
+	c.entries[filepath.Clean(remotePath)] = statEntry{info: info, expires: time.Now().Add(ttl)}
+}
+
+// invalidate removes remotePath and every path below it from the cache.
+func (c *statCache) invalidate(remotePath string) {
+	remotePath = filepath.Clean(remotePath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.entries {
+		if p == remotePath || strings.HasPrefix(p, remotePath+"/") {
+			delete(c.entries, p)
+		}
+	}
+}
+
+// statCacheTTL returns how long remote os.FileInfo are cached, or 0 if the cache is disabled.
+func (s *SFTP) statCacheTTL() time.Duration {
+	if s.config.DisableStatCache {
+		return 0
+	}
+	if s.config.StatCacheTTL > 0 {
+		return s.config.StatCacheTTL
+	}
+	return defaultStatCacheTTL
+}
+
+// statRemote returns the os.FileInfo of the remote path, from the cache if it was looked up recently.
+//
+// Parameters:
+//   - remotePath: The path of the remote file or directory.
+//
+// Returns:
+//   - os.FileInfo: The information about the remote path.
+//   - error: If the path cannot be stat'ed. Errors are not cached.
+func (s *SFTP) statRemote(remotePath string) (os.FileInfo, error) {
+	ttl := s.statCacheTTL()
+	if ttl > 0 {
+		if info, ok := s.statCache.get(remotePath); ok {
+			return info, nil
+		}
+	}
+	info, err := s.Client.Stat(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		s.statCache.put(remotePath, info, ttl)
+	}
+	return info, nil
+}
+
+// cacheRemoteEntries caches the entries of a remote directory listing.
+func (s *SFTP) cacheRemoteEntries(remoteDir string, entries []os.FileInfo) {
+	ttl := s.statCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+	for _, entry := range entries {
+		s.statCache.put(remoteJoin(remoteDir, entry.Name()), entry, ttl)
+	}
+}
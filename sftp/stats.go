@@ -0,0 +1,73 @@
+package sftp
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// DirStats holds the number of files and bytes transferred in each direction.
+type DirStats struct {
+	//FilesUploaded is the number of files uploaded to the remote server
+	FilesUploaded int64
+	//BytesUploaded is the number of bytes uploaded to the remote server
+	BytesUploaded int64
+	//FilesDownloaded is the number of files downloaded from the remote server
+	FilesDownloaded int64
+	//BytesDownloaded is the number of bytes downloaded from the remote server
+	BytesDownloaded int64
+}
+
+// add adds a transfer of n bytes in the given direction to the stats.
+func (d *DirStats) add(upload bool, n int64) {
+	if upload {
+		d.FilesUploaded++
+		d.BytesUploaded += n
+	} else {
+		d.FilesDownloaded++
+		d.BytesDownloaded += n
+	}
+}
+
+// transferStats accounts the transfers of an SFTP connection. It is safe for concurrent use by the workers.
+type transferStats struct {
+	mu    sync.Mutex
+	total DirStats
+	byDir map[string]DirStats
+}
+
+// record adds a completed transfer of n bytes of the file at relativePath to the stats.
+func (t *transferStats) record(relativePath string, upload bool, n int64) {
+	dir := filepath.ToSlash(filepath.Dir(relativePath))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byDir == nil {
+		t.byDir = make(map[string]DirStats)
+	}
+	t.total.add(upload, n)
+	stats := t.byDir[dir]
+	stats.add(upload, n)
+	t.byDir[dir] = stats
+}
+
+// Stats returns the number of files and bytes transferred in each direction since the connection was established.
+func (s *SFTP) Stats() DirStats {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	return s.stats.total
+}
+
+// StatsByDir returns the transfer stats broken down by the directory the files are in, relative to LocalDir
+// and RemoteDir ("." for the root), to find the parts of the tree that dominate the traffic.
+//
+// Returns:
+//   - map[string]DirStats: A copy of the stats by slash-separated directory, safe to modify.
+func (s *SFTP) StatsByDir() map[string]DirStats {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	byDir := make(map[string]DirStats, len(s.stats.byDir))
+	for dir, stats := range s.stats.byDir {
+		byDir[dir] = stats
+	}
+	return byDir
+}
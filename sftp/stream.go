@@ -0,0 +1,94 @@
+package sftp
+
+import (
+	"context"
+	"io"
+	"path"
+)
+
+// DownloadToWriter streams a remote file into w without writing it to disk, e.g. into an HTTP response, a
+// decompressor or a cipher. The transfer reports its progress like the downloads of the sync.
+//
+// Parameters:
+//   - ctx: The context of the call. The transfer stops between two reads once it is done.
+//   - remotePath: The path of the remote file, used as is rather than relative to RemoteDir.
+//   - w: The writer the content of the file is copied to.
+//
+// Returns:
+//   - error: If the remote file cannot be read, w fails, or ctx is done before the transfer completes.
+func (s *SFTP) DownloadToWriter(ctx context.Context, remotePath string, w io.Writer) error {
+	client, release := s.acquire(0)
+	defer release()
+	srcFile, err := client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	total := int64(-1)
+	if info, err := srcFile.Stat(); err == nil {
+		total = info.Size()
+	}
+	_, err = s.copyWithProgress(w, ctxReader{ctx: ctx, reader: srcFile}, remotePath, total)
+	return err
+}
+
+// UploadFromReader streams the content of r into a remote file without reading it from disk. The parent directories
+// of the file are created as needed, and with AtomicUploads the file is replaced only once the upload completes.
+//
+// Parameters:
+//   - ctx: The context of the call. The transfer stops between two reads once it is done.
+//   - r: The reader the content of the file is copied from, until io.EOF.
+//   - remotePath: The path of the remote file, used as is rather than relative to RemoteDir.
+//
+// Returns:
+//   - error: If the remote file cannot be written, r fails, or ctx is done before the transfer completes.
+func (s *SFTP) UploadFromReader(ctx context.Context, r io.Reader, remotePath string) error {
+	// Uploads over a single connection are serialized, as in uploadFileTo.
+	if len(s.conns) < 2 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	defer s.lockFile(remotePath)()
+	client, release := s.acquire(0)
+	defer release()
+	defer s.statCache.invalidate(remotePath)
+
+	err := s.dirs.ensure(path.Dir(remotePath), func(dir string) error { return mkdirAllRemote(client, dir, s.config.DirMode) })
+	if err != nil {
+		return err
+	}
+	dstFile, err := client.Create(s.uploadTarget(remotePath))
+	if err != nil {
+		return err
+	}
+	_, err = s.copyWithProgress(dstFile, ctxReader{ctx: ctx, reader: r}, remotePath, -1)
+	closeErr := dstFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.commitUpload(client, remotePath)
+	}
+	if err != nil {
+		s.abortUpload(client, remotePath)
+		return err
+	}
+	return nil
+}
+
+// ctxReader is an io.Reader that fails with the error of ctx once it is done, so a copy from it stops between two
+// reads.
+type ctxReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
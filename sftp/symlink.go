@@ -0,0 +1,27 @@
+package sftp
+
+import (
+	"fmt"
+	"os"
+)
+
+// createLocal creates or truncates the local file a download is written to, like os.Create.
+//
+// With NoFollowLocalSymlinks, a symlink at localPath is not followed: the download fails instead, so a remote tree
+// cannot redirect writes outside LocalDir through a symlink planted in it.
+//
+// Parameters:
+//   - localPath: The path of the local file.
+//
+// Returns:
+//   - *os.File: The file, opened for writing.
+//   - error: If the file cannot be created, or if it is a symlink and NoFollowLocalSymlinks is set.
+func (s *SFTP) createLocal(localPath string) (*os.File, error) {
+	if s.config.NoFollowLocalSymlinks {
+		info, err := os.Lstat(localPath)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("sftp: refusing to write through symlink %s", localPath)
+		}
+	}
+	return os.Create(localPath)
+}
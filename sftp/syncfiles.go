@@ -0,0 +1,81 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// SyncFiles transfers exactly the listed files in the current direction and returns once they are transferred, for
+// workflows that compute the changed files themselves, e.g. from a CI diff. Unlike TriggerFullSync, no tree is
+// walked: each file is uploaded, or downloaded, whether or not its destination copy is up to date, and the parent
+// directories it needs are created. Removals are not synced; a file missing from the source fails.
+//
+// Ignored files (TempFilePatterns, hidden files without SyncHidden) and files rejected by the EventFilter, which sees
+// them as Create events, are skipped. Uploads honor AtomicUploads and take the per-file lock of the workers, so
+// SyncFiles may run while the workers process changes.
+//
+// Parameters:
+//   - relPaths: The paths of the files relative to LocalDir and RemoteDir, with either separator.
+//
+// Returns:
+//   - error: The errors of the files that could not be transferred, joined with errors.Join, or ErrPathTraversal
+//     for a path that would resolve outside LocalDir.
+func (s *SFTP) SyncFiles(relPaths []string) error {
+	direction := s.Direction()
+	var errs []error
+	for _, relPath := range relPaths {
+		err := s.syncFile(direction, relPath)
+		if err != nil {
+			logger.Errorf("Error syncing %s: %v", relPath, err)
+			errs = append(errs, fmt.Errorf("%s: %w", relPath, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// syncFile transfers one of the files of SyncFiles.
+//
+// Parameters:
+//   - direction: The sync direction the file is transferred in.
+//   - relPath: The path of the file relative to LocalDir and RemoteDir.
+//
+// Returns:
+//   - error: If the file cannot be transferred.
+func (s *SFTP) syncFile(direction SyncDirection, relPath string) error {
+	localPath, err := safeJoin(s.config.LocalDir, filepath.FromSlash(relPath))
+	if err != nil {
+		return err
+	}
+	if localPath == filepath.Clean(s.config.LocalDir) {
+		return fmt.Errorf("%q is not a file path", relPath)
+	}
+	remotePath := toRemotePath(s.config.LocalDir, localPath, s.config.RemoteDir)
+
+	if direction == RemoteToLocal {
+		if s.ignored(remotePath) || !s.acceptEvent(fsnotify.Event{Name: remotePath, Op: fsnotify.Create}) {
+			logger.Debug("Skipping filtered file:", remotePath)
+			return nil
+		}
+		return s.downloadFileTo(0, remotePath, localPath)
+	}
+
+	if s.ignored(localPath) || !s.acceptEvent(fsnotify.Event{Name: localPath, Op: fsnotify.Create}) {
+		logger.Debug("Skipping filtered file:", localPath)
+		return nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", localPath)
+	}
+	if skip, err := s.skipSpecial(localPath, info.Mode()); skip {
+		return err
+	}
+	return s.uploadFileTo(0, localPath, remotePath)
+}
@@ -0,0 +1,138 @@
+package sftp
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// SyncOnce synchronizes LocalDir and RemoteDir once, without watching for changes, for scheduled sync jobs.
+// With SyncSince, SyncAfter or a last sync time, only the files modified after it are transferred, and
+// LastSyncTime is advanced to the modification time of the most recently modified file transferred.
+//
+// With ArchiveMode, the whole tree is transferred as a single archive instead; if that fails, e.g. because the
+// server has no shell, the files are synced one by one.
+//
+// Returns:
+//   - error: If the synchronization fails. The last sync time is left unchanged.
+func (s *SFTP) SyncOnce() error {
+	if s.config.ArchiveMode {
+		err := s.archiveSync(s.ctx)
+		if err == nil {
+			s.runPostUploadIfIdle()
+			return nil
+		}
+		if s.ctx.Err() != nil {
+			return err
+		}
+		logger.Warn("Archive transfer failed, syncing the files one by one:", err)
+	}
+	err := s.initialSync()
+	if err != nil {
+		return err
+	}
+	s.runPostUploadIfIdle()
+	return nil
+}
+
+// LastSyncTime returns the modification time of the most recently modified file transferred by the last
+// successful sync, as set by the sync or SetLastSyncTime, or read from SyncStatePath.
+// The sync only tracks it once SyncStatePath is set or SetLastSyncTime has been called with a non-zero time,
+// so restarts of WatchDirectory keep syncing the whole tree by default.
+//
+// Returns:
+//   - time.Time: The last sync time, or the zero time if there is none.
+func (s *SFTP) LastSyncTime() time.Time {
+	t, err := s.lastSyncTime()
+	if err != nil {
+		logger.Error("Error reading the last sync time:", err)
+	}
+	return t
+}
+
+// SetLastSyncTime sets the time the next sync only transfers files modified after, when neither SyncSince nor
+// SyncAfter is set. It is stored in SyncStatePath, if set; an error storing it is logged.
+//
+// Parameters:
+//   - t: The new last sync time, or the zero time to make the next sync transfer the whole tree.
+func (s *SFTP) SetLastSyncTime(t time.Time) {
+	err := s.setLastSyncTime(t)
+	if err != nil {
+		logger.Error("Error storing the last sync time:", err)
+	}
+}
+
+// lastSyncTime returns the last sync time, reading it from SyncStatePath the first time.
+func (s *SFTP) lastSyncTime() (time.Time, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if s.lastSync.IsZero() && s.config.SyncStatePath != "" {
+		data, err := os.ReadFile(s.config.SyncStatePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, nil
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+		if err != nil {
+			return time.Time{}, err
+		}
+		s.lastSync = t
+	}
+	return s.lastSync, nil
+}
+
+// setLastSyncTime sets the last sync time and stores it in SyncStatePath, if set. The file is replaced
+// atomically, so an interrupted write leaves the previous time in place.
+func (s *SFTP) setLastSyncTime(t time.Time) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.lastSync = t
+	if s.config.SyncStatePath == "" {
+		return nil
+	}
+	tmp := s.config.SyncStatePath + ".tmp"
+	err := os.WriteFile(tmp, []byte(t.Format(time.RFC3339Nano)+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.SyncStatePath)
+}
+
+// tracksLastSync reports whether the initial sync advances the last sync time.
+func (s *SFTP) tracksLastSync() bool {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	return s.config.SyncStatePath != "" || !s.lastSync.IsZero()
+}
+
+// syncCutoffTime returns the time the initial sync only transfers files modified after: the later of SyncSince
+// and SyncAfter if either is set, otherwise the last sync time, or the zero time to sync the whole tree.
+func (s *SFTP) syncCutoffTime() (time.Time, error) {
+	cutoff := s.config.SyncSince
+	if s.config.SyncAfter.After(cutoff) {
+		cutoff = s.config.SyncAfter
+	}
+	if !cutoff.IsZero() {
+		return cutoff, nil
+	}
+	return s.lastSyncTime()
+}
+
+// shouldTransfer reports whether the initial sync transfers a file modified at modTime: with a cutoff, any file
+// modified after it; otherwise any file missing on the other side.
+func (s *SFTP) shouldTransfer(modTime time.Time, missing func() bool) bool {
+	if !s.syncCutoff.IsZero() {
+		return modTime.After(s.syncCutoff)
+	}
+	return missing()
+}
+
+// transferred records that the initial sync transferred a file modified at modTime.
+func (s *SFTP) transferred(modTime time.Time) {
+	if modTime.After(s.syncNewest) {
+		s.syncNewest = modTime
+	}
+}
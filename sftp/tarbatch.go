@@ -0,0 +1,306 @@
+package sftp
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/internal/ctxio"
+)
+
+// tarBatch collects the small files of the initial sync, which are then transferred together as a tar stream
+// through a single remote tar command, instead of one SFTP transfer each.
+type tarBatch struct {
+	entries []tarEntry
+}
+
+// tarEntry is a file collected in a tarBatch.
+type tarEntry struct {
+	//path is the path of the file in the source directory: local for uploads, remote for downloads
+	path string
+	//name is the slash-separated path of the file relative to the source directory, as stored in the archive
+	name string
+	//size is the size of the file when it was collected
+	size int64
+	//modTime is the modification time of the file when it was collected
+	modTime time.Time
+}
+
+// batchesTar reports whether the initial sync transfers small files as a tar stream: with BatchSmallFiles, over an
+// SSH connection that can run commands, and without AtomicUploads, whose temporary files tar does not write.
+func (s *SFTP) batchesTar() bool {
+	return s.config.BatchSmallFiles && !s.config.AtomicUploads && s.sshConn != nil
+}
+
+// collectTar adds a file the initial sync is about to transfer to the tar batch, if one is collected and the file is
+// at most SmallFileThreshold bytes.
+//
+// Parameters:
+//   - sourcePath: The path of the file below the source directory.
+//   - info: The file info of the file.
+//
+// Returns:
+//   - bool: True if the file was added to the batch, false if it must be transferred on its own.
+func (s *SFTP) collectTar(sourcePath string, info os.FileInfo) bool {
+	if s.syncTar == nil || !info.Mode().IsRegular() || info.Size() > s.smallFileThreshold() || s.ignored(sourcePath) {
+		return false
+	}
+	relativePath, err := filepath.Rel(s.sourceDir(), sourcePath)
+	if err != nil {
+		return false
+	}
+	// The names are sent to the remote tar one per line.
+	name := filepath.ToSlash(relativePath)
+	if strings.ContainsAny(name, "\r\n") {
+		return false
+	}
+	s.syncTar.entries = append(s.syncTar.entries, tarEntry{path: sourcePath, name: name, size: info.Size(), modTime: info.ModTime()})
+	return true
+}
+
+// flushTar transfers the files of the tar batch of the initial sync. If the remote tar command fails, e.g. because
+// the server only offers SFTP and no shell, the files are transferred one by one instead.
+//
+// Returns:
+//   - error: If a file cannot be transferred one by one either.
+func (s *SFTP) flushTar() error {
+	if s.syncTar == nil || len(s.syncTar.entries) == 0 {
+		return nil
+	}
+	entries := s.syncTar.entries
+	direction := s.Direction()
+	var err error
+	if direction == RemoteToLocal {
+		err = s.downloadTar(entries)
+	} else {
+		err = s.uploadTar(entries)
+	}
+	if err != nil {
+		logger.Warn("Tar batch failed, transferring the files one by one:", err)
+		for _, entry := range entries {
+			if direction == RemoteToLocal {
+				err = s.downloadFile(entry.path)
+			} else {
+				err = s.uploadFile(entry.path)
+			}
+			if err != nil {
+				return err
+			}
+			s.transferred(entry.modTime)
+		}
+		return nil
+	}
+	for _, entry := range entries {
+		s.transferred(entry.modTime)
+	}
+	return nil
+}
+
+// uploadTar streams entries as a tar archive to "tar -x" run in RemoteDir on the remote server.
+//
+// Parameters:
+//   - entries: The local files to upload.
+//
+// Returns:
+//   - error: If the archive cannot be written, or the remote command fails (the error includes its stderr).
+func (s *SFTP) uploadTar(entries []tarEntry) error {
+	session, err := s.sshConn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("tar -x -f - -C %s", shellQuote(s.config.RemoteDir))
+	logger.Debugf("Uploading %d small files with %s", len(entries), command)
+	err = session.Start(command)
+	if err != nil {
+		return err
+	}
+
+	writeErr := writeTar(stdin, entries)
+	closeErr := stdin.Close()
+	err = session.Wait()
+	if err != nil {
+		return fmt.Errorf("remote command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	for _, entry := range entries {
+		remotePath := path.Join(filepath.ToSlash(s.config.RemoteDir), entry.name)
+		s.statCache.invalidate(remotePath)
+		s.sendProgress(ProgressEvent{Filename: entry.path, BytesTransferred: entry.size, TotalBytes: entry.size, Done: true})
+		s.stats.record(filepath.FromSlash(entry.name), true, entry.size)
+	}
+	atomic.StoreInt32(&s.uploaded, 1)
+	return nil
+}
+
+// writeTar writes entries to w as a tar archive.
+func writeTar(w io.Writer, entries []tarEntry) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		err := writeTarEntry(tw, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeTarEntry writes the header and the content of a local file to tw.
+func writeTarEntry(tw *tar.Writer, entry tarEntry) error {
+	file, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = entry.name
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	err = tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, file, header.Size)
+	return err
+}
+
+// downloadTar runs "tar -c" in RemoteDir on the remote server for entries and extracts the archive it writes into
+// LocalDir. The names are passed on the standard input of tar, so there is no limit on their number.
+//
+// Parameters:
+//   - entries: The remote files to download.
+//
+// Returns:
+//   - error: If the remote command fails (the error includes its stderr), or a file of the archive cannot be written.
+func (s *SFTP) downloadTar(entries []tarEntry) error {
+	session, err := s.sshConn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	var names strings.Builder
+	for _, entry := range entries {
+		// The ./ prefix keeps tar from reading names beginning with a dash as options.
+		names.WriteString("./" + entry.name + "\n")
+	}
+	var stderr bytes.Buffer
+	session.Stdin = strings.NewReader(names.String())
+	session.Stderr = &stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("tar -c -f - -C %s -T -", shellQuote(s.config.RemoteDir))
+	logger.Debugf("Downloading %d small files with %s", len(entries), command)
+	err = session.Start(command)
+	if err != nil {
+		return err
+	}
+
+	extractErr := s.extractTar(stdout, entries)
+	_, _ = io.Copy(io.Discard, stdout)
+	err = session.Wait()
+	if err != nil {
+		return fmt.Errorf("remote command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
+
+// extractTar writes the regular files of the tar archive read from r that are listed in entries into LocalDir.
+//
+// Returns:
+//   - error: If a file cannot be written or is missing from the archive, or if a name would escape LocalDir.
+func (s *SFTP) extractTar(r io.Reader, entries []tarEntry) error {
+	wanted := make(map[string]tarEntry, len(entries))
+	for _, entry := range entries {
+		wanted[entry.name] = entry
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(header.Name)
+		entry, ok := wanted[name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		err = s.extractTarEntry(tr, header, entry)
+		if err != nil {
+			return err
+		}
+		delete(wanted, name)
+	}
+	if len(wanted) > 0 {
+		return fmt.Errorf("%d files are missing from the tar archive", len(wanted))
+	}
+	return nil
+}
+
+// extractTarEntry writes the content of the current file of tr, described by header, to the local copy of entry.
+func (s *SFTP) extractTarEntry(tr *tar.Reader, header *tar.Header, entry tarEntry) error {
+	localPath, err := safeJoin(s.config.LocalDir, filepath.FromSlash(entry.name))
+	if err != nil {
+		return err
+	}
+	err = s.dirs.ensure(filepath.Dir(localPath), func(dir string) error { return os.MkdirAll(dir, 0755) })
+	if err != nil {
+		return err
+	}
+	file, err := s.createLocal(localPath)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(file, ctxio.Reader(s.ctx, tr))
+	if err == nil {
+		s.applyRemoteMode(file, header.FileInfo().Mode())
+	}
+	closeErr := file.Close()
+	s.sendProgress(ProgressEvent{Filename: localPath, BytesTransferred: n, TotalBytes: entry.size, Done: true})
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	s.stats.record(filepath.FromSlash(entry.name), false, n)
+	return nil
+}
+
+// shellQuote quotes s as a single argument of a POSIX shell command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,60 @@
+package sftp
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// NewSharedTransport dials an SSH connection to the server at the specified address and port, authenticating
+// with the username and password in config, to be set as ExtraConfig.SharedTransport of several SFTP instances.
+// The connection goes through the ProxyURL and JumpHost of config if set.
+//
+// Parameters:
+//   - address: The IP address or hostname of the remote SFTP server.
+//   - port: The port number to connect to on the remote server.
+//   - config: The configuration holding the credentials, the proxy and the jump host.
+//
+// Returns:
+//   - *ssh.Client: The SSH connection. The caller must close it once every SFTP using it is done.
+//   - error: If the server, the proxy or the jump host cannot be reached, or the server rejects the credentials.
+//
+// Example:
+//
+//	transport, err := NewSharedTransport("example.com", 22, &ExtraConfig{Username: "user", Password: "pass"})
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	defer transport.Close()
+//	site, err := Connect("example.com", 22, LocalToRemote, &ExtraConfig{LocalDir: "./site", RemoteDir: "/var/www", SharedTransport: transport})
+//	assets, err := Connect("example.com", 22, LocalToRemote, &ExtraConfig{LocalDir: "./assets", RemoteDir: "/var/assets", SharedTransport: transport})
+func NewSharedTransport(address string, port int, config *ExtraConfig) (*ssh.Client, error) {
+	dial, jump, err := newDialer(config)
+	if err != nil {
+		return nil, err
+	}
+	closeJump := func() {
+		if jump != nil {
+			_ = jump.Close()
+		}
+	}
+	addr := fmt.Sprintf("%s:%d", address, port)
+	conn, err := dial("tcp", addr)
+	if err != nil {
+		closeJump()
+		return nil, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, passwordClientConfig(config))
+	if err != nil {
+		_ = conn.Close()
+		closeJump()
+		return nil, err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	go func() {
+		// The jump host is only used by this connection, so it is closed along with it.
+		_ = client.Wait()
+		closeJump()
+	}()
+	return client, nil
+}
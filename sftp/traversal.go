@@ -0,0 +1,55 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathTraversal is returned when a path reported by the server would resolve outside the directory it belongs in,
+// e.g. a file name containing "../", so nothing is written or removed there.
+var ErrPathTraversal = errors.New("sftp: path escapes its directory")
+
+// isDotEntry reports whether name is one of the "." and ".." entries some servers include in directory listings.
+// They name the directory itself and its parent, so they are skipped rather than synced, walked into or removed.
+func isDotEntry(name string) bool {
+	return name == "." || name == ".."
+}
+
+// safeJoin joins name to dir like filepath.Join, but fails with ErrPathTraversal if the cleaned result is not dir
+// or a path below it.
+func safeJoin(dir, name string) (string, error) {
+	joined := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, joined)
+	}
+	return joined, nil
+}
+
+// safeRemoteJoin joins name to the remote directory dir like path.Join, with forward slashes whatever the local
+// separator, but fails with ErrPathTraversal if the cleaned result is not dir or a path below it. safeJoin would
+// build remote paths with backslashes on Windows, which SFTP servers reject.
+func safeRemoteJoin(dir, name string) (string, error) {
+	joined := path.Join(filepath.ToSlash(dir), name)
+	rel := path.Clean(strings.TrimLeft(name, "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, joined)
+	}
+	return joined, nil
+}
+
+// localPath returns the local counterpart of remotePath, a path below RemoteDir.
+//
+// Returns:
+//   - string: The path below LocalDir.
+//   - error: ErrPathTraversal if remotePath is not below RemoteDir or the local path would escape LocalDir.
+func (s *SFTP) localPath(remotePath string) (string, error) {
+	relativePath, err := filepath.Rel(s.config.RemoteDir, remotePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, remotePath)
+	}
+	return safeJoin(s.config.LocalDir, relativePath)
+}
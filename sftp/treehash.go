@@ -0,0 +1,39 @@
+package sftp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"sort"
+)
+
+// treeHash returns the SHA-256 hash of the paths, sizes and modification times of files, in path order, so two walks
+// of a tree in which no file was added, removed or modified have the same hash.
+//
+// Parameters:
+//   - files: The files of a walk, by path.
+//
+// Returns:
+//   - [sha256.Size]byte: The hash of the tree.
+func treeHash(files map[string]os.FileInfo) [sha256.Size]byte {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	var buf [16]byte
+	for _, path := range paths {
+		info := files[path]
+		// The path is terminated by a NUL byte, which cannot appear in it, so paths and numbers cannot run together.
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		binary.BigEndian.PutUint64(buf[:8], uint64(info.Size()))
+		binary.BigEndian.PutUint64(buf[8:], uint64(info.ModTime().UnixNano()))
+		h.Write(buf[:])
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,155 @@
+package sftp
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// A path that was a file may have become a directory between syncs, or the other way around. The helpers below
+// remove the entry of the wrong type on the destination so it can be recreated with the right one.
+
+// remoteInfo returns the os.FileInfo of a remote path, from the directory listing of readRemoteDirCache when
+// available and a Stat call otherwise.
+func (s *SFTP) remoteInfo(cache map[string]os.FileInfo, remotePath string) (os.FileInfo, bool) {
+	if cache != nil {
+		info, ok := cache[path.Base(remotePath)]
+		return info, ok
+	}
+	info, err := s.statRemote(remotePath)
+	return info, err == nil
+}
+
+// replaceRemote removes the remote entry described by info if it is not of the wanted type, a directory if dir is
+// true and a file otherwise.
+//
+// Returns:
+//   - bool: True if the entry was removed.
+//   - error: If the entry could not be removed.
+func (s *SFTP) replaceRemote(remotePath string, info os.FileInfo, dir bool) (bool, error) {
+	if info == nil || info.IsDir() == dir {
+		return false, nil
+	}
+	logger.Info("Remote type changed, replacing:", remotePath)
+	if info.IsDir() {
+		return true, s.RemoveRemoteDir(s.ctx, remotePath)
+	}
+	defer s.statCache.invalidate(remotePath)
+	s.dirs.forget(remotePath)
+	return true, s.Client.Remove(remotePath)
+}
+
+// replaceLocal removes the local entry at localPath if it is not of the wanted type, a directory if dir is true
+// and a file otherwise.
+//
+// Returns:
+//   - bool: True if the entry was removed.
+//   - error: If the entry could not be removed.
+func (s *SFTP) replaceLocal(localPath string, dir bool) (bool, error) {
+	info, err := os.Lstat(localPath)
+	if err != nil || info.IsDir() == dir {
+		return false, nil
+	}
+	logger.Info("Local type changed, replacing:", localPath)
+	s.dirs.forget(localPath)
+	return true, os.RemoveAll(localPath)
+}
+
+// ensureRemoteDir creates the remote directory remotePath, replacing a file of the same name.
+func (s *SFTP) ensureRemoteDir(client *sftp.Client, remotePath string) error {
+	info, err := client.Stat(remotePath)
+	if err == nil {
+		_, err = s.replaceRemote(remotePath, info, true)
+		if err != nil {
+			return err
+		}
+	}
+	return client.MkdirAll(remotePath)
+}
+
+// replaceRemoteConflicts prepares remotePath, a path below RemoteDir, to be created as a file: a directory at
+// remotePath and files where its parent directories should be are removed, and the parent directories created.
+//
+// Returns:
+//   - bool: True if anything was replaced, so creating the file is worth another attempt.
+//   - error: If an entry could not be replaced.
+func (s *SFTP) replaceRemoteConflicts(client *sftp.Client, remotePath string) (bool, error) {
+	relativePath, err := filepath.Rel(s.config.RemoteDir, remotePath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return false, err
+	}
+	replaced := false
+	dir := filepath.ToSlash(s.config.RemoteDir)
+	parts := strings.Split(relativePath, string(filepath.Separator))
+	for i, part := range parts {
+		dir = path.Join(dir, part)
+		info, err := client.Stat(dir)
+		if err != nil {
+			break
+		}
+		ok, err := s.replaceRemote(dir, info, i < len(parts)-1)
+		if err != nil {
+			return replaced, err
+		}
+		replaced = replaced || ok
+	}
+	if replaced {
+		return true, client.MkdirAll(path.Dir(remotePath))
+	}
+	return false, nil
+}
+
+// replaceLocalConflicts prepares localPath, a path below LocalDir, to be created as a file: a directory at
+// localPath and files where its parent directories should be are removed, and the parent directories created.
+//
+// Returns:
+//   - bool: True if anything was replaced, so creating the file is worth another attempt.
+//   - error: If an entry could not be replaced.
+func (s *SFTP) replaceLocalConflicts(localPath string) (bool, error) {
+	relativePath, err := filepath.Rel(s.config.LocalDir, localPath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return false, err
+	}
+	replaced := false
+	dir := s.config.LocalDir
+	parts := strings.Split(relativePath, string(filepath.Separator))
+	for i, part := range parts {
+		dir = filepath.Join(dir, part)
+		ok, err := s.replaceLocal(dir, i < len(parts)-1)
+		if err != nil {
+			return replaced, err
+		}
+		replaced = replaced || ok
+	}
+	if replaced {
+		return true, os.MkdirAll(filepath.Dir(localPath), 0755)
+	}
+	return false, nil
+}
+
+// staleRemoval reports whether a Remove task no longer applies because the path was recreated, e.g. as a
+// directory replacing a file, or because its parent on the destination was replaced by a file.
+func (s *SFTP) staleRemoval(task string, direction SyncDirection) bool {
+	switch direction {
+	case LocalToRemote:
+		if _, err := os.Lstat(task); err == nil {
+			return true
+		}
+		relativePath, err := filepath.Rel(s.config.LocalDir, task)
+		if err != nil {
+			return false
+		}
+		info, err := s.Client.Stat(path.Dir(remoteJoin(s.config.RemoteDir, relativePath)))
+		return err == nil && !info.IsDir()
+	case RemoteToLocal:
+		if _, err := s.Client.Stat(task); err == nil {
+			return true
+		}
+		info, err := os.Stat(filepath.Dir(s.convertRemoteToLocalPath(task)))
+		return err == nil && !info.IsDir()
+	}
+	return false
+}
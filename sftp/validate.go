@@ -0,0 +1,72 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Validate checks config for the mistakes that would otherwise only surface as confusing failures during the sync.
+//
+// Returns:
+//   - error: The problems found, joined with errors.Join, or nil if config is valid.
+func (config *ExtraConfig) Validate() error {
+	if config == nil {
+		return errors.New("sftp: config is nil")
+	}
+	var errs []error
+	if config.LocalDir == "" {
+		errs = append(errs, errors.New("sftp: LocalDir is empty"))
+	} else if info, err := os.Stat(config.LocalDir); err != nil {
+		errs = append(errs, fmt.Errorf("sftp: LocalDir: %w", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("sftp: LocalDir %q is not a directory", config.LocalDir))
+	}
+	if config.RemoteDir == "" {
+		errs = append(errs, errors.New("sftp: RemoteDir is empty"))
+	} else if !strings.HasPrefix(config.RemoteDir, "/") {
+		errs = append(errs, fmt.Errorf("sftp: RemoteDir %q is not an absolute path", config.RemoteDir))
+	}
+	if config.MaxRetries < 1 {
+		errs = append(errs, fmt.Errorf("sftp: MaxRetries is %d, it must be at least 1", config.MaxRetries))
+	}
+	if config.ProxyURL != "" {
+		if u, err := url.Parse(config.ProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("sftp: ProxyURL: %w", err))
+		} else if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			errs = append(errs, fmt.Errorf("sftp: ProxyURL scheme %q is not supported, use socks5", u.Scheme))
+		}
+	}
+	if config.CaseCollisionPolicy < CaseCollisionOff || config.CaseCollisionPolicy > CaseCollisionRename {
+		errs = append(errs, fmt.Errorf("sftp: CaseCollisionPolicy %d is not valid", config.CaseCollisionPolicy))
+	}
+	if config.OnError < OnErrorAbort || config.OnError > OnErrorCollect {
+		errs = append(errs, fmt.Errorf("sftp: OnError %d is not valid", config.OnError))
+	}
+	if config.LogLevel < Debug || config.LogLevel > Error {
+		errs = append(errs, fmt.Errorf("sftp: LogLevel %d is not valid", config.LogLevel))
+	}
+	if config.Workers < 0 {
+		errs = append(errs, fmt.Errorf("sftp: Workers is %d, it must not be negative", config.Workers))
+	}
+	if config.MinWorkers < 0 {
+		errs = append(errs, fmt.Errorf("sftp: MinWorkers is %d, it must not be negative", config.MinWorkers))
+	}
+	if config.MaxWorkers < 0 {
+		errs = append(errs, fmt.Errorf("sftp: MaxWorkers is %d, it must not be negative", config.MaxWorkers))
+	} else if config.MaxWorkers > 0 && config.MinWorkers > config.MaxWorkers {
+		errs = append(errs, fmt.Errorf("sftp: MinWorkers %d is above MaxWorkers %d", config.MinWorkers, config.MaxWorkers))
+	}
+	if c := config.ArchiveCompression; c != "" && c != "gz" && c != "zstd" {
+		errs = append(errs, fmt.Errorf("sftp: ArchiveCompression %q is not supported, use \"gz\" or \"zstd\"", c))
+	}
+	if config.ClientVersion != "" && !strings.HasPrefix(config.ClientVersion, "SSH-2.0-") {
+		errs = append(errs, fmt.Errorf("sftp: ClientVersion %q does not start with \"SSH-2.0-\"", config.ClientVersion))
+	}
+	if config.JumpHost != nil && config.JumpHost.Address == "" {
+		errs = append(errs, errors.New("sftp: JumpHost.Address is empty"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package sftp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cploutarchou/syncpkg/internal/ctxio"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Verify checks that LocalDir and RemoteDir are in sync without changing either, e.g. as a post-sync assertion in
+// CI or monitoring: both must hold the same regular files, with the same sizes and SHA-256 checksums. The trees are
+// walked as by TriggerFullSync, and the files of the same size are read on both sides to compare their checksums.
+//
+// Ignored files (TempFilePatterns, hidden files without SyncHidden) and files rejected by the EventFilter, which sees
+// them as Create events, are left out on both sides, as in SyncFiles.
+//
+// Returns:
+//   - bool: True if the trees are in sync.
+//   - []string: The discrepancies, one per file, sorted by path relative to LocalDir and RemoteDir, such as
+//     "a/b.txt: missing remotely", "a/b.txt: missing locally", "a/b.txt: size differs (local 3, remote 4)" or
+//     "a/b.txt: checksum differs".
+//   - error: If a tree cannot be walked, or the errors of the files that could not be read, joined with
+//     errors.Join. The trees are then not reported in sync.
+func (s *SFTP) Verify() (bool, []string, error) {
+	localFiles := make(map[string]os.FileInfo)
+	remoteFiles := make(map[string]os.FileInfo)
+	err := walkLocalDir(s.config.LocalDir, localFiles)
+	if err == nil {
+		err = s.walkRemoteDir(s.config.RemoteDir, remoteFiles)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	type pair struct {
+		localPath, remotePath string
+		local, remote         os.FileInfo
+	}
+	// The files of both trees by their path relative to LocalDir and RemoteDir.
+	files := make(map[string]*pair)
+	relative := func(localPath string) (string, bool) {
+		if s.ignored(localPath) || !s.acceptEvent(fsnotify.Event{Name: localPath, Op: fsnotify.Create}) {
+			return "", false
+		}
+		relativePath, err := filepath.Rel(s.config.LocalDir, localPath)
+		if err != nil {
+			return "", false
+		}
+		return filepath.ToSlash(relativePath), true
+	}
+	for localPath, info := range localFiles {
+		if relativePath, ok := relative(localPath); ok {
+			files[relativePath] = &pair{localPath: localPath, local: info}
+		}
+	}
+	for remotePath, info := range remoteFiles {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		localPath, err := s.localPath(remotePath)
+		if err != nil {
+			continue
+		}
+		relativePath, ok := relative(localPath)
+		if !ok {
+			continue
+		}
+		p, ok := files[relativePath]
+		if !ok {
+			p = &pair{localPath: localPath}
+			files[relativePath] = p
+		}
+		p.remotePath, p.remote = remotePath, info
+	}
+
+	var discrepancies []string
+	var errs []error
+	for relativePath, p := range files {
+		switch {
+		case p.remote == nil:
+			discrepancies = append(discrepancies, relativePath+": missing remotely")
+		case p.local == nil:
+			discrepancies = append(discrepancies, relativePath+": missing locally")
+		case p.local.Size() != p.remote.Size():
+			discrepancies = append(discrepancies, fmt.Sprintf("%s: size differs (local %d, remote %d)",
+				relativePath, p.local.Size(), p.remote.Size()))
+		default:
+			localSum, err := fileSHA256(p.localPath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			remoteSum, err := s.remoteFileSHA256(p.remotePath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if localSum != remoteSum {
+				discrepancies = append(discrepancies, relativePath+": checksum differs")
+			}
+		}
+	}
+	sort.Strings(discrepancies)
+	err = errors.Join(errs...)
+	return err == nil && len(discrepancies) == 0, discrepancies, err
+}
+
+// remoteFileSHA256 returns the hex SHA-256 checksum of the content of the remote file path, like fileSHA256 for
+// local files. Reading stops when the SFTP is closed.
+func (s *SFTP) remoteFileSHA256(path string) (string, error) {
+	file, err := s.Client.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, ctxio.Reader(s.ctx, file))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
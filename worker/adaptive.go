@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveWindow       = 10  // adaptiveWindow is the number of finished tasks the scaler evaluates at a time.
+	adaptiveMaxErrorRate = 0.1 // adaptiveMaxErrorRate is the share of failed tasks in a window above which the limit halves.
+	adaptiveSlowdown     = 2   // adaptiveSlowdown is how many times the baseline latency a window may average before the limit drops.
+)
+
+// adaptiveScaler limits how many workers of a pool process tasks at once, and adjusts the limit to the outcome of
+// the tasks, see PoolConfig.AdaptiveWorkers. After each window of finished tasks, it halves the limit if too many
+// failed, lowers it by one if they took much longer than the baseline, the fastest windows seen, and raises it by
+// one if tasks are still queued. The methods do nothing on a nil scaler, the scaler of a pool without
+// AdaptiveWorkers.
+type adaptiveScaler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	min, max int
+	limit    int // limit is the number of tasks the workers may process at once.
+	inFlight int // inFlight is the number of workers holding a slot, waiting for or processing a task.
+	pending  func() int
+
+	finished int           // finished is the number of tasks finished in the current window.
+	failed   int           // failed is the number of tasks of the current window that failed.
+	elapsed  time.Duration // elapsed is the total duration of the tasks of the current window that succeeded.
+	baseline time.Duration // baseline is the average task duration the windows are compared to.
+}
+
+// newAdaptiveScaler returns a scaler starting at min tasks at once, adjusting up to max, and reading the number of
+// queued tasks from pending.
+func newAdaptiveScaler(min, max int, pending func() int) *adaptiveScaler {
+	a := &adaptiveScaler{min: min, max: max, limit: min, pending: pending}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// acquire waits until fewer workers than the limit hold a slot, and takes one.
+func (a *adaptiveScaler) acquire() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	for a.inFlight >= a.limit {
+		a.cond.Wait()
+	}
+	a.inFlight++
+	a.mu.Unlock()
+}
+
+// release gives back the slot taken by acquire.
+func (a *adaptiveScaler) release() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.inFlight--
+	a.mu.Unlock()
+	a.cond.Signal()
+}
+
+// observe records a task that took duration and failed with err, if not nil, and adjusts the limit at the end of
+// a window.
+func (a *adaptiveScaler) observe(duration time.Duration, err error) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.finished++
+	if err != nil {
+		a.failed++
+	} else {
+		a.elapsed += duration
+	}
+	if a.finished < adaptiveWindow {
+		return
+	}
+
+	limit := a.limit
+	succeeded := a.finished - a.failed
+	switch {
+	case float64(a.failed) > adaptiveMaxErrorRate*float64(a.finished):
+		limit /= 2
+	case succeeded > 0:
+		average := a.elapsed / time.Duration(succeeded)
+		if a.baseline == 0 || average < a.baseline {
+			a.baseline = average
+		}
+		if average > adaptiveSlowdown*a.baseline {
+			limit--
+			// Move the baseline toward the slower tasks, so a server that stays slow is eventually taken as the
+			// new normal and the limit can grow again.
+			a.baseline += (average - a.baseline) / 4
+		} else if a.pending() > 0 {
+			limit++
+		}
+	}
+	if limit < a.min {
+		limit = a.min
+	}
+	if limit > a.max {
+		limit = a.max
+	}
+	grew := limit > a.limit
+	a.limit = limit
+	if grew {
+		a.cond.Broadcast()
+	}
+	a.finished, a.failed, a.elapsed = 0, 0, 0
+}
+
+// current returns the limit.
+func (a *adaptiveScaler) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// WorkerLimit returns the number of tasks the workers process at once: MaxWorkers, or with AdaptiveWorkers the
+// limit the pool has currently adjusted to, between MinWorkers and MaxWorkers.
+func (p *Pool) WorkerLimit() int {
+	if p.adaptive == nil {
+		return p.config.MaxWorkers
+	}
+	return p.adaptive.current()
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the time-based behavior: poll intervals, time windows, backoffs and timeouts.
+// It lets tests drive that behavior with a FakeClock instead of real sleeps.
+type Clock interface {
+	Now() time.Time                         // Now returns the current time.
+	After(d time.Duration) <-chan time.Time // After returns a channel that receives the current time once d has elapsed.
+	Sleep(d time.Duration)                  // Sleep blocks until d has elapsed.
+}
+
+// RealClock is the Clock of the time package.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sleep calls time.Sleep(d).
+func (RealClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// ClockOrReal returns clock, or RealClock if clock is nil, for the configs whose Clock is optional.
+func ClockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock{}
+	}
+	return clock
+}
+
+// FakeClock is a Clock whose time only moves when Advance is called, for deterministic tests.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter is a channel returned by FakeClock.After and the time it fires at.
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time of the clock once Advance has moved it d forward.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	c.changed.Broadcast()
+	return ch
+}
+
+// Sleep blocks until Advance has moved the clock d forward.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the clock d forward and fires the After channels and Sleep calls that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+	c.changed.Broadcast()
+}
+
+// BlockUntil blocks until n After channels or Sleep calls are waiting for the clock to move, so a test can
+// Advance it once the code under test is known to be waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
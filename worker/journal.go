@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// errJournalClosed is returned by Add, Done and Failed after Close.
+var errJournalClosed = errors.New("worker: journal is closed")
+
+// Journal is a file-backed record of the tasks that have been queued but have not completed yet.
+// The tasks it holds can be replayed after a reconnect or a restart of the process.
+//
+// The file is a log of one JSON record per line, which Add, Done and Failed append to, so recording a change costs
+// the same whatever the number of pending tasks. OpenJournal and Close compact it down to the pending tasks.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File // file is the journal file opened for appending, nil after Close.
+	pending []Task
+}
+
+// journalRecord is a line of the journal file.
+type journalRecord struct {
+	Op   string // Op is "add", "done" or "failed", see Journal.apply.
+	Task Task
+}
+
+// OpenJournal opens the journal stored at path, loading the tasks that were pending when it was last written, and
+// compacts it. A missing file is treated as an empty journal. A last record that was only partly written, e.g.
+// because the process stopped, is ignored.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	err = j.load(data)
+	if err != nil {
+		return nil, err
+	}
+	err = j.compact()
+	if err != nil {
+		return nil, err
+	}
+	j.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Add records task as pending. A task already pending is recorded only once.
+func (j *Journal) Add(task Task) error {
+	return j.record(journalRecord{Op: "add", Task: task})
+}
+
+// Done removes task from the pending tasks.
+func (j *Journal) Done(task Task) error {
+	return j.record(journalRecord{Op: "done", Task: task})
+}
+
+// Failed records that processing task failed by incrementing the RetryCount of the pending task, so the task is
+// replayed as its next attempt. A task that is not pending is ignored.
+func (j *Journal) Failed(task Task) error {
+	task.RetryCount++
+	return j.record(journalRecord{Op: "failed", Task: task})
+}
+
+// Pending returns the pending tasks in the order they were added.
+func (j *Journal) Pending() []Task {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Task(nil), j.pending...)
+}
+
+// Close compacts the journal down to the pending tasks and closes its file. Pending keeps working after Close,
+// while Add, Done and Failed return an error. Calling Close again does nothing.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return errors.Join(err, j.compact())
+}
+
+// record applies rec to the pending tasks and, if it changed them, appends it to the journal file.
+func (j *Journal) record(rec journalRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return errJournalClosed
+	}
+	if !j.apply(rec) {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+// apply applies rec to the pending tasks and reports whether they changed: "add" appends a task that is not
+// pending yet, "done" removes it, and "failed" sets its RetryCount to the one of rec.
+func (j *Journal) apply(rec journalRecord) bool {
+	for i, t := range j.pending {
+		if !sameTask(t, rec.Task) {
+			continue
+		}
+		switch rec.Op {
+		case "done":
+			j.pending = append(j.pending[:i], j.pending[i+1:]...)
+			return true
+		case "failed":
+			j.pending[i].RetryCount = rec.Task.RetryCount
+			return true
+		}
+		return false
+	}
+	if rec.Op == "add" {
+		j.pending = append(j.pending, rec.Task)
+		return true
+	}
+	return false
+}
+
+// load replays the records of data, the content of the journal file, into the pending tasks. Journals saved as a
+// JSON array of the pending tasks, as written before the file became a log, are loaded too.
+func (j *Journal) load(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &j.pending)
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var rec journalRecord
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			if i == len(lines)-1 {
+				// The process stopped while the record was written.
+				return nil
+			}
+			return err
+		}
+		j.apply(rec)
+	}
+	return nil
+}
+
+// compact writes an "add" record for each pending task to a temporary file and renames it over the journal,
+// so the journal is never left half written.
+func (j *Journal) compact() error {
+	var buf bytes.Buffer
+	for _, task := range j.pending {
+		data, err := json.Marshal(journalRecord{Op: "add", Task: task})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	tmp := j.path + ".tmp"
+	err := os.WriteFile(tmp, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// sameTask reports whether a and b are the same event on the same file, whatever their priority.
+func sameTask(a, b Task) bool {
+	return a.EventType == b.EventType && a.Name == b.Name
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %s", err)
+	}
+	a := Task{EventType: fsnotify.Create, Name: "a.txt"}
+	b := Task{EventType: fsnotify.Write, Name: "b.txt"}
+	for _, record := range []func() error{
+		func() error { return journal.Add(a) },
+		func() error { return journal.Add(b) },
+		func() error { return journal.Add(a) },
+		func() error { return journal.Failed(b) },
+		func() error { return journal.Done(a) },
+	} {
+		if err := record(); err != nil {
+			t.Fatalf("Failed to record a task: %s", err)
+		}
+	}
+
+	// Every change is appended to the file as a record, and duplicates are not.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 4 {
+		t.Errorf("Expected 4 records in the journal, got %d:\n%s", n, data)
+	}
+
+	// A record only partly written when the process stopped is ignored on reopening, and the journal is compacted.
+	if err := os.WriteFile(path, append(data, `{"Op":"done","Ta`...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %s", err)
+	}
+	if pending := journal.Pending(); len(pending) != 1 || pending[0].Name != "b.txt" || pending[0].RetryCount != 1 {
+		t.Fatalf("Expected b.txt pending with a RetryCount of 1, got %+v", pending)
+	}
+	if data, err = os.ReadFile(path); err != nil || strings.Count(string(data), "\n") != 1 {
+		t.Errorf("Expected the reopened journal to hold a single record, got %q, %v", data, err)
+	}
+
+	if err := journal.Add(a); err != nil {
+		t.Fatalf("Failed to record a task: %s", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %s", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Errorf("Expected closing the journal again to do nothing, got %s", err)
+	}
+	if err := journal.Done(a); err == nil {
+		t.Error("Expected Done to fail after Close")
+	}
+	if data, err = os.ReadFile(path); err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Errorf("Expected the closed journal to hold two records, got %q, %v", data, err)
+	}
+
+	// Journals saved as a JSON array of the pending tasks are still loaded.
+	if err := os.WriteFile(path, []byte(`[{"EventType":1,"Name":"old.txt","RetryCount":2}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Failed to open a journal saved as an array: %s", err)
+	}
+	defer journal.Close()
+	if pending := journal.Pending(); len(pending) != 1 || pending[0].Name != "old.txt" || pending[0].RetryCount != 2 {
+		t.Errorf("Expected old.txt pending with a RetryCount of 2, got %+v", pending)
+	}
+
+	// A corrupted record before the last one is an error.
+	if err := os.WriteFile(path, []byte("not json\n{\"Op\":\"add\",\"Task\":{\"Name\":\"x\"}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenJournal(path); err == nil {
+		t.Error("Expected a corrupted journal to fail to open")
+	}
+}
@@ -0,0 +1,16 @@
+package worker
+
+// TaskGetString returns the string stored in the Metadata of t under key.
+// It returns false if there is no value under key or the value is not a string.
+func TaskGetString(t Task, key string) (string, bool) {
+	value, ok := t.Metadata[key].(string)
+	return value, ok
+}
+
+// TaskSetString stores value in the Metadata of t under key, creating the map if needed.
+func TaskSetString(t *Task, key, value string) {
+	if t.Metadata == nil {
+		t.Metadata = make(map[string]any)
+	}
+	t.Metadata[key] = value
+}
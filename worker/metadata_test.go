@@ -0,0 +1,27 @@
+package worker
+
+import "testing"
+
+func TestTaskMetadata(t *testing.T) {
+	var task Task
+	if value, ok := TaskGetString(task, "checksum"); ok || value != "" {
+		t.Errorf("TaskGetString() = %q, %v on a nil map, want \"\", false", value, ok)
+	}
+
+	TaskSetString(&task, "checksum", "abc")
+	if value, ok := TaskGetString(task, "checksum"); !ok || value != "abc" {
+		t.Errorf("TaskGetString() = %q, %v, want \"abc\", true", value, ok)
+	}
+	if value, ok := TaskGetString(task, "missing"); ok || value != "" {
+		t.Errorf("TaskGetString() = %q, %v for a missing key, want \"\", false", value, ok)
+	}
+
+	task.Metadata["size"] = int64(42)
+	if value, ok := TaskGetString(task, "size"); ok || value != "" {
+		t.Errorf("TaskGetString() = %q, %v for a non-string value, want \"\", false", value, ok)
+	}
+	TaskSetString(&task, "size", "42")
+	if value, ok := TaskGetString(task, "size"); !ok || value != "42" {
+		t.Errorf("TaskGetString() = %q, %v after overwriting, want \"42\", true", value, ok)
+	}
+}
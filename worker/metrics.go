@@ -0,0 +1,63 @@
+package worker
+
+import "time"
+
+// MetricsRecorder receives the metrics of a pool, so any monitoring backend can be plugged in by implementing it.
+// The pool records enqueued tasks and the queue depth, and the workers record how the tasks they processed ended.
+// Its methods are called concurrently from the workers and must not block.
+type MetricsRecorder interface {
+	RecordTaskEnqueued()                        // RecordTaskEnqueued is called when Submit queues a task.
+	RecordTaskCompleted(duration time.Duration) // RecordTaskCompleted is called when a task was processed successfully in duration.
+	RecordTaskFailed(err error)                 // RecordTaskFailed is called when processing a task failed with err.
+	RecordQueueDepth(depth int)                 // RecordQueueDepth is called with the number of queued tasks whenever it changes.
+}
+
+// NoopMetricsRecorder is a MetricsRecorder that discards the metrics. It is the recorder of a pool until
+// SetMetricsRecorder is called.
+type NoopMetricsRecorder struct{}
+
+// RecordTaskEnqueued does nothing.
+func (NoopMetricsRecorder) RecordTaskEnqueued() {}
+
+// RecordTaskCompleted does nothing.
+func (NoopMetricsRecorder) RecordTaskCompleted(time.Duration) {}
+
+// RecordTaskFailed does nothing.
+func (NoopMetricsRecorder) RecordTaskFailed(error) {}
+
+// RecordQueueDepth does nothing.
+func (NoopMetricsRecorder) RecordQueueDepth(int) {}
+
+// SetMetricsRecorder makes the pool and its workers report their metrics to recorder. A nil recorder restores
+// the NoopMetricsRecorder.
+func (p *Pool) SetMetricsRecorder(recorder MetricsRecorder) {
+	if recorder == nil {
+		recorder = NoopMetricsRecorder{}
+	}
+	p.metricsMu.Lock()
+	p.metrics = recorder
+	p.metricsMu.Unlock()
+}
+
+// Metrics returns the MetricsRecorder of the pool, which the workers report the outcome of their tasks to.
+func (p *Pool) Metrics() MetricsRecorder {
+	p.metricsMu.RLock()
+	defer p.metricsMu.RUnlock()
+	if p.metrics == nil {
+		return NoopMetricsRecorder{}
+	}
+	return p.metrics
+}
+
+// RecordTask reports the outcome of a task a worker started processing at start: RecordTaskFailed if err is not
+// nil, and RecordTaskCompleted with the time elapsed on clock otherwise. With AdaptiveWorkers, the outcome also
+// adjusts WorkerLimit.
+func (p *Pool) RecordTask(clock Clock, start time.Time, err error) {
+	duration := ClockOrReal(clock).Now().Sub(start)
+	p.adaptive.observe(duration, err)
+	if err != nil {
+		p.Metrics().RecordTaskFailed(err)
+		return
+	}
+	p.Metrics().RecordTaskCompleted(duration)
+}
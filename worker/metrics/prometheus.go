@@ -0,0 +1,102 @@
+// Package metrics provides MetricsRecorder implementations for the worker pool.
+//
+// PrometheusMetricsRecorder keeps the metrics of a pool in memory and serves them over HTTP in the Prometheus text
+// exposition format, so a Prometheus server can scrape them without the module depending on the Prometheus client
+// library:
+//
+//	recorder := metrics.NewPrometheusMetricsRecorder("syncpkg")
+//	pool.SetMetricsRecorder(recorder)
+//	http.Handle("/metrics", recorder)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/cploutarchou/syncpkg/worker"
+)
+
+// PrometheusMetricsRecorder is a worker.MetricsRecorder that counts the tasks of a pool and serves the counts in the
+// Prometheus text exposition format. It is safe for concurrent use.
+type PrometheusMetricsRecorder struct {
+	namespace string
+	enqueued  uint64
+	completed uint64
+	failed    uint64
+	duration  uint64 // duration holds the float64 bits of the total seconds spent on completed tasks.
+	depth     int64
+}
+
+var _ worker.MetricsRecorder = (*PrometheusMetricsRecorder)(nil)
+
+// NewPrometheusMetricsRecorder returns a PrometheusMetricsRecorder whose metric names are prefixed with namespace and
+// an underscore, or not prefixed if namespace is empty.
+func NewPrometheusMetricsRecorder(namespace string) *PrometheusMetricsRecorder {
+	return &PrometheusMetricsRecorder{namespace: namespace}
+}
+
+// RecordTaskEnqueued counts a queued task.
+func (r *PrometheusMetricsRecorder) RecordTaskEnqueued() {
+	atomic.AddUint64(&r.enqueued, 1)
+}
+
+// RecordTaskCompleted counts a completed task and adds duration to the time spent on completed tasks.
+func (r *PrometheusMetricsRecorder) RecordTaskCompleted(duration time.Duration) {
+	atomic.AddUint64(&r.completed, 1)
+	for {
+		old := atomic.LoadUint64(&r.duration)
+		sum := math.Float64bits(math.Float64frombits(old) + duration.Seconds())
+		if atomic.CompareAndSwapUint64(&r.duration, old, sum) {
+			return
+		}
+	}
+}
+
+// RecordTaskFailed counts a failed task.
+func (r *PrometheusMetricsRecorder) RecordTaskFailed(error) {
+	atomic.AddUint64(&r.failed, 1)
+}
+
+// RecordQueueDepth sets the number of queued tasks.
+func (r *PrometheusMetricsRecorder) RecordQueueDepth(depth int) {
+	atomic.StoreInt64(&r.depth, int64(depth))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (r *PrometheusMetricsRecorder) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+//
+// Returns the number of bytes written and the first write error.
+func (r *PrometheusMetricsRecorder) WriteTo(w io.Writer) (int64, error) {
+	metrics := []struct {
+		name, kind, help string
+		value            interface{}
+	}{
+		{"tasks_enqueued_total", "counter", "Number of tasks submitted to the pool.", atomic.LoadUint64(&r.enqueued)},
+		{"tasks_completed_total", "counter", "Number of tasks processed successfully.", atomic.LoadUint64(&r.completed)},
+		{"tasks_failed_total", "counter", "Number of tasks whose processing failed.", atomic.LoadUint64(&r.failed)},
+		{"task_duration_seconds_total", "counter", "Total time spent on tasks processed successfully.", math.Float64frombits(atomic.LoadUint64(&r.duration))},
+		{"queue_depth", "gauge", "Number of queued tasks not yet handed to a worker.", atomic.LoadInt64(&r.depth)},
+	}
+	var written int64
+	for _, m := range metrics {
+		name := m.name
+		if r.namespace != "" {
+			name = r.namespace + "_" + name
+		}
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, m.help, name, m.kind, name, m.value)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetricsRecorder(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("syncpkg")
+	for i := 0; i < 3; i++ {
+		recorder.RecordTaskEnqueued()
+	}
+	recorder.RecordTaskCompleted(1500 * time.Millisecond)
+	recorder.RecordTaskCompleted(time.Second)
+	recorder.RecordTaskFailed(errors.New("upload failed"))
+	recorder.RecordQueueDepth(7)
+	recorder.RecordQueueDepth(4)
+
+	var buf bytes.Buffer
+	n, err := recorder.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo() = %d, %v, want %d, nil", n, err, buf.Len())
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"# HELP syncpkg_tasks_enqueued_total Number of tasks submitted to the pool.\n",
+		"# TYPE syncpkg_tasks_enqueued_total counter\nsyncpkg_tasks_enqueued_total 3\n",
+		"# TYPE syncpkg_tasks_completed_total counter\nsyncpkg_tasks_completed_total 2\n",
+		"# TYPE syncpkg_tasks_failed_total counter\nsyncpkg_tasks_failed_total 1\n",
+		"# TYPE syncpkg_task_duration_seconds_total counter\nsyncpkg_task_duration_seconds_total 2.5\n",
+		"# TYPE syncpkg_queue_depth gauge\nsyncpkg_queue_depth 4\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteTo() output is missing %q:\n%s", want, got)
+		}
+	}
+
+	// Without a namespace, the names are not prefixed.
+	buf.Reset()
+	if _, err := NewPrometheusMetricsRecorder("").WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "# TYPE tasks_enqueued_total counter\ntasks_enqueued_total 0\n") ||
+		strings.Contains(got, "_tasks_enqueued_total") {
+		t.Errorf("Unexpected output without a namespace:\n%s", got)
+	}
+
+	response := httptest.NewRecorder()
+	recorder.ServeHTTP(response, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", contentType)
+	}
+	if !strings.Contains(response.Body.String(), "syncpkg_tasks_failed_total 1\n") {
+		t.Errorf("Unexpected response body:\n%s", response.Body.String())
+	}
+}
+
+// failingWriter accepts limit bytes and then fails.
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("write failed")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestPrometheusMetricsRecorderWriteError(t *testing.T) {
+	recorder := NewPrometheusMetricsRecorder("syncpkg")
+	n, err := recorder.WriteTo(&failingWriter{limit: 10})
+	if err == nil || n != 10 {
+		t.Errorf("WriteTo() = %d, %v, want 10 and the write error", n, err)
+	}
+}
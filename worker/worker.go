@@ -5,10 +5,11 @@
 // (e.g., creation, write, removal) and the Name of the file associated with the event.
 //
 // To use the worker pool, create a new Pool using NewWorkerPool, specifying the capacity of the pool,
-// i.e., the maximum number of concurrent workers. Then, tasks can be submitted to the worker pool
-// through the Tasks channel. Each worker goroutine in the pool will process tasks as they arrive.
-// The worker pool ensures that tasks are processed in a concurrent and synchronized manner,
-// allowing for efficient processing of multiple tasks simultaneously.
+// i.e., the maximum number of concurrent workers. Then, start the workers with Start and submit tasks
+// with Submit. Each worker receives its tasks with Next and marks them processed with Done, and
+// exits when Next returns false. Stop makes the workers exit once the queued tasks are processed, discarding the
+// tasks submitted while it runs, and Close shuts the pool down for good. Metrics of the pool and its tasks are
+// reported to the MetricsRecorder set with SetMetricsRecorder.
 //
 // Example usage:
 //
@@ -16,18 +17,31 @@
 //	pool := NewWorkerPool(10)
 //
 //	// Start the worker goroutines to process tasks
-//	for i := 0; i < cap(pool.Tasks); i++ {
-//	  go pool.Worker()
-//	}
+//	pool.Start(cap(pool.Tasks), func() {
+//	  for {
+//	    task, ok := pool.Next()
+//	    if !ok {
+//	      return
+//	    }
+//	    process(task)
+//	    pool.Done()
+//	  }
+//	})
 //
 //	// Submit tasks to the worker pool
-//	pool.Tasks <- Task{EventType: fsnotify.Create, Name: "file1.txt"}
-//	pool.Tasks <- Task{EventType: fsnotify.Write, Name: "file2.txt"}
-//	pool.Tasks <- Task{EventType: fsnotify.Remove, Name: "file3.txt"}
+//	pool.Submit(Task{EventType: fsnotify.Create, Name: "file1.txt"})
+//	pool.Submit(Task{EventType: fsnotify.Write, Name: "file2.txt"})
+//	pool.Submit(Task{EventType: fsnotify.Remove, Name: "file3.txt"})
+//
+//	// Wait for the tasks and stop the workers
+//	pool.WG.Wait()
+//	pool.Stop()
 package worker
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -38,18 +52,375 @@ import (
 type Task struct {
 	EventType fsnotify.Op
 	Name      string
+	Priority  Priority // Priority is PriorityHigh for tasks processed ahead of the normal ones.
+	// RetryCount is the number of times the task failed and was resubmitted, 0 when it is first queued. Retry
+	// increments it, and the journal keeps it up to date, see Journal.Failed.
+	RetryCount int `json:",omitempty"`
+	// Metadata carries values computed along the pipeline, such as file sizes or checksums, without changing the
+	// struct. It is saved in the journal, so the values must be JSON-encodable. See TaskGetString and TaskSetString.
+	Metadata map[string]any `json:",omitempty"`
+
+	stop bool // stop marks the poison pill Stop queues to make a worker exit.
+}
+
+// Priority orders the processing of queued tasks.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // PriorityNormal tasks are processed in the order they were submitted.
+	PriorityHigh                   // PriorityHigh tasks are processed before the queued PriorityNormal tasks, see urgentBurst.
+)
+
+// urgentBurst is the number of PriorityHigh tasks Next hands out in a row while PriorityNormal tasks are queued,
+// before it hands out a PriorityNormal one, so a steady stream of PriorityHigh tasks cannot starve them.
+const urgentBurst = 8
+
+// PoolConfig holds the optional settings of a worker pool.
+type PoolConfig struct {
+	IdleWorkerTimeout time.Duration // IdleWorkerTimeout is how long a worker waits for a task before exiting (0 keeps workers forever).
+	MaxWorkers        int           // MaxWorkers is the maximum number of workers Submit scales up to (defaults to the capacity).
+	Clock             Clock         // Clock times IdleWorkerTimeout (defaults to RealClock).
+	AdaptiveWorkers   bool          // AdaptiveWorkers adjusts how many workers process tasks at once to the latency and errors of the tasks.
+	MinWorkers        int           // MinWorkers is the number of workers AdaptiveWorkers starts with and never goes below (defaults to 1).
 }
 
 // Pool is a pool of worker goroutines that can process tasks concurrently.
 type Pool struct {
-	Tasks chan Task      // Tasks is the channel through which tasks are submitted to the worker pool.
+	Tasks chan Task      // Tasks is the channel through which PriorityNormal tasks are submitted to the worker pool.
 	WG    sync.WaitGroup // WG is used to wait for all worker goroutines to finish their tasks.
+
+	urgent        chan Task
+	config        PoolConfig
+	activeWorkers int64
+	spawnMu       sync.Mutex
+	spawn         func()
+	running       sync.WaitGroup
+	closeMu       sync.RWMutex
+	closed        int32
+	pause         sync.RWMutex
+	pauseMu       sync.Mutex
+	paused        int32
+	metricsMu     sync.RWMutex
+	metrics       MetricsRecorder
+	adaptive      *adaptiveScaler
+	urgentStreak  int32 // urgentStreak is the number of PriorityHigh tasks handed out in a row while PriorityNormal tasks were queued.
+	stops         int32 // stops is the number of poison pills taken ahead of PriorityHigh tasks, see Next.
 }
 
 // NewWorkerPool constructs a new WorkerPool with the given capacity.
 // The capacity specifies the maximum number of concurrent workers in the pool.
 func NewWorkerPool(capacity int) *Pool {
-	return &Pool{
-		Tasks: make(chan Task, capacity),
+	return NewWorkerPoolWithConfig(capacity, PoolConfig{})
+}
+
+// NewWorkerPoolWithConfig constructs a new WorkerPool with the given capacity and config.
+// With an IdleWorkerTimeout, idle workers exit and Submit starts new ones when tasks back up.
+// With AdaptiveWorkers, the number of tasks processed at once starts at MinWorkers and is adjusted between
+// MinWorkers and MaxWorkers: it drops when tasks fail or slow down, and grows while they are fast and tasks are
+// queued. The outcome of the tasks is read from the RecordTask calls of the workers, see WorkerLimit.
+func NewWorkerPoolWithConfig(capacity int, config PoolConfig) *Pool {
+	if config.MaxWorkers <= 0 {
+		config.MaxWorkers = capacity
+	}
+	if config.MinWorkers <= 0 {
+		config.MinWorkers = 1
+	}
+	if config.MinWorkers > config.MaxWorkers {
+		config.MinWorkers = config.MaxWorkers
+	}
+	p := &Pool{
+		Tasks:  make(chan Task, capacity),
+		urgent: make(chan Task, capacity),
+		config: config,
+	}
+	if config.AdaptiveWorkers {
+		p.adaptive = newAdaptiveScaler(config.MinWorkers, config.MaxWorkers, p.Pending)
+	}
+	return p
+}
+
+// Start starts n goroutines running worker, which must receive its tasks with Next.
+// The pool keeps worker to start new goroutines when Submit scales up after idle workers exited.
+func (p *Pool) Start(n int, worker func()) {
+	p.spawnMu.Lock()
+	p.spawn = worker
+	p.spawnMu.Unlock()
+	for i := 0; i < n; i++ {
+		atomic.AddInt64(&p.activeWorkers, 1)
+		p.run(worker)
+	}
+}
+
+// Stop makes the running workers exit once they have processed the tasks queued before the call, and waits for them.
+// Rather than closing the Tasks channel, which panics producers still submitting, it queues one poison pill per
+// worker; Next consumes it and returns false. Submit no longer starts workers until Start is called again.
+//
+// Tasks submitted while Stop runs, queued behind the poison pills, are discarded once the workers exited: they are
+// removed from WG, so WG.Wait does not wait for them, and are never processed.
+func (p *Pool) Stop() {
+	p.spawnMu.Lock()
+	p.spawn = nil
+	p.spawnMu.Unlock()
+	p.closeMu.RLock()
+	if !p.Closed() {
+		for i := p.ActiveWorkers(); i > 0; i-- {
+			p.Tasks <- Task{stop: true}
+		}
+	}
+	p.closeMu.RUnlock()
+	p.running.Wait()
+	atomic.StoreInt32(&p.stops, 0)
+	p.discardQueued()
+}
+
+// discardQueued empties the queues after the workers exited, removing the discarded tasks from WG. Poison pills left
+// by workers that exited idle are removed too, so they do not stop the workers of the next Start.
+func (p *Pool) discardQueued() {
+	defer func() { p.Metrics().RecordQueueDepth(p.Pending()) }()
+	for {
+		var task Task
+		select {
+		case task = <-p.urgent:
+		default:
+			var ok bool
+			select {
+			case task, ok = <-p.Tasks:
+				if !ok {
+					return
+				}
+			default:
+				return
+			}
+		}
+		if !task.stop {
+			p.WG.Done()
+		}
+	}
+}
+
+// run starts worker in a new goroutine tracked by Stop.
+func (p *Pool) run(worker func()) {
+	p.running.Add(1)
+	go func() {
+		defer p.running.Done()
+		worker()
+	}()
+}
+
+// Submit queues task, adding it to WG, and starts a new worker if the queued tasks outnumber the running workers.
+// The task and the new queue depth are reported to the MetricsRecorder.
+// PriorityHigh tasks are queued separately and handed to the workers before the queued PriorityNormal tasks, except
+// that one PriorityNormal task is handed out after every urgentBurst PriorityHigh tasks.
+// Tasks submitted after Close are dropped.
+func (p *Pool) Submit(task Task) {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.Closed() {
+		return
+	}
+	p.WG.Add(1)
+	if task.Priority > PriorityNormal {
+		p.urgent <- task
+	} else {
+		p.Tasks <- task
+	}
+	p.Metrics().RecordTaskEnqueued()
+	p.Metrics().RecordQueueDepth(p.Pending())
+	p.scale()
+}
+
+// Retry resubmits task, which failed, with its RetryCount incremented. A worker calls it before marking the task
+// Done, so WG does not drop to zero in between. Unlike Submit, it never blocks: if the queue is full, the task is
+// queued by a new goroutine once a worker makes room. Tasks retried after Close are dropped.
+func (p *Pool) Retry(task Task) {
+	task.RetryCount++
+	p.closeMu.RLock()
+	if p.Closed() {
+		p.closeMu.RUnlock()
+		return
+	}
+	p.WG.Add(1)
+	queue := p.Tasks
+	if task.Priority > PriorityNormal {
+		queue = p.urgent
+	}
+	select {
+	case queue <- task:
+		p.closeMu.RUnlock()
+	default:
+		go func() {
+			defer p.closeMu.RUnlock()
+			queue <- task
+		}()
+	}
+	p.Metrics().RecordTaskEnqueued()
+	p.Metrics().RecordQueueDepth(p.Pending())
+	p.scale()
+}
+
+// Close closes the Tasks channel and waits for the workers to process the queued tasks, PriorityHigh ones included,
+// and return. Submit calls in progress complete their send first and later ones drop their task, so closing never
+// races with a send. Tasks left queued with no worker to take them, e.g. when the pool was never started, are
+// discarded, so WG.Wait does not hang. Unlike Stop, the pool cannot be started again. Close is safe to call more
+// than once.
+func (p *Pool) Close() {
+	p.closeMu.Lock()
+	if atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		close(p.Tasks)
+	}
+	p.closeMu.Unlock()
+	p.running.Wait()
+	p.closeMu.Lock()
+	defer p.closeMu.Unlock()
+	p.discardQueued()
+}
+
+// Closed reports whether Close was called.
+func (p *Pool) Closed() bool {
+	return atomic.LoadInt32(&p.closed) == 1
+}
+
+// Next waits for the next task. It returns false when the Tasks channel is closed and drained, when Stop asked the
+// worker to exit, or when no task arrived within IdleWorkerTimeout, in which case the calling worker must exit.
+// Tasks returned by Next are marked processed by the worker with Done; the poison pills are not counted.
+// With AdaptiveWorkers, Next first waits until fewer workers than WorkerLimit are processing or waiting for a task.
+func (p *Pool) Next() (Task, bool) {
+	for {
+		p.adaptive.acquire()
+		if atomic.LoadInt32(&p.urgentStreak) >= urgentBurst {
+			select {
+			case task, ok := <-p.Tasks:
+				if !task.stop || !ok {
+					return p.received(task, ok)
+				}
+				// The poison pill was taken ahead of the PriorityHigh tasks queued before Stop; the worker exits
+				// once they are processed.
+				atomic.AddInt32(&p.stops, 1)
+				atomic.StoreInt32(&p.urgentStreak, 0)
+			default:
+			}
+		}
+		if stops := atomic.LoadInt32(&p.stops); stops > 0 && len(p.urgent) == 0 &&
+			atomic.CompareAndSwapInt32(&p.stops, stops, stops-1) {
+			return p.received(Task{stop: true}, true)
+		}
+		select {
+		case task := <-p.urgent:
+			return p.received(task, true)
+		default:
+		}
+		var idle <-chan time.Time
+		if p.config.IdleWorkerTimeout > 0 {
+			idle = ClockOrReal(p.config.Clock).After(p.config.IdleWorkerTimeout)
+		}
+		select {
+		case task := <-p.urgent:
+			return p.received(task, true)
+		case task, ok := <-p.Tasks:
+			return p.received(task, ok)
+		case <-idle:
+		}
+		p.adaptive.release()
+		atomic.AddInt64(&p.activeWorkers, -1)
+		// A task submitted while the worker was timing out may not have started a new worker.
+		if p.Pending() == 0 {
+			return Task{}, false
+		}
+		atomic.AddInt64(&p.activeWorkers, 1)
+	}
+}
+
+// received returns a task received by Next, turning a poison pill into the signal to exit.
+// While the pool is paused, it waits for Resume before handing the task over.
+func (p *Pool) received(task Task, ok bool) (Task, bool) {
+	if !ok {
+		// Tasks is closed: the PriorityHigh tasks still queued are processed before the worker exits.
+		select {
+		case task = <-p.urgent:
+			ok = true
+		default:
+		}
+	}
+	if task.stop {
+		p.adaptive.release()
+		atomic.AddInt64(&p.activeWorkers, -1)
+		return Task{}, false
+	}
+	if !ok {
+		p.adaptive.release()
+		atomic.AddInt64(&p.activeWorkers, -1)
+		return task, ok
+	}
+	if task.Priority > PriorityNormal && len(p.Tasks) > 0 {
+		atomic.AddInt32(&p.urgentStreak, 1)
+	} else {
+		atomic.StoreInt32(&p.urgentStreak, 0)
+	}
+	p.Metrics().RecordQueueDepth(p.Pending())
+	p.pause.RLock()
+	return task, ok
+}
+
+// Done marks a task returned by Next as processed: it is removed from WG and no longer holds off Pause.
+func (p *Pool) Done() {
+	p.pause.RUnlock()
+	p.adaptive.release()
+	p.WG.Done()
+}
+
+// Pause waits for the tasks being processed to be done and keeps the workers from starting new ones until Resume.
+// Queued and newly submitted tasks are kept, and the watchers keep running. Pausing a paused pool does nothing.
+//
+// Note: Stop and Close wait for the queued tasks, so they block until the pool is resumed.
+func (p *Pool) Pause() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.IsPaused() {
+		return
+	}
+	p.pause.Lock()
+	atomic.StoreInt32(&p.paused, 1)
+}
+
+// Resume lets the workers process tasks again after Pause. Resuming a running pool does nothing.
+func (p *Pool) Resume() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if !p.IsPaused() {
+		return
+	}
+	atomic.StoreInt32(&p.paused, 0)
+	p.pause.Unlock()
+}
+
+// IsPaused reports whether the pool is paused, e.g. for health checks.
+func (p *Pool) IsPaused() bool {
+	return atomic.LoadInt32(&p.paused) == 1
+}
+
+// Pending returns the number of queued tasks, of any priority, not yet handed to a worker.
+func (p *Pool) Pending() int {
+	return len(p.Tasks) + len(p.urgent)
+}
+
+// ActiveWorkers returns the number of workers started by Start or Submit that have not exited.
+func (p *Pool) ActiveWorkers() int {
+	return int(atomic.LoadInt64(&p.activeWorkers))
+}
+
+// scale starts a new worker when the queued tasks outnumber the running workers, up to WorkerLimit.
+func (p *Pool) scale() {
+	p.spawnMu.Lock()
+	defer p.spawnMu.Unlock()
+	if p.spawn == nil {
+		return
+	}
+	active := atomic.LoadInt64(&p.activeWorkers)
+	if active >= int64(p.WorkerLimit()) {
+		return
+	}
+	if active == 0 || int64(p.Pending()) > active {
+		atomic.AddInt64(&p.activeWorkers, 1)
+		p.run(p.spawn)
 	}
 }
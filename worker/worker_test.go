@@ -0,0 +1,358 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// recorder is a worker that records the names of the tasks it processes.
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// worker returns the worker function passed to Start, processing the tasks of pool.
+func (r *recorder) worker(pool *Pool) func() {
+	return func() {
+		for {
+			task, ok := pool.Next()
+			if !ok {
+				return
+			}
+			r.mu.Lock()
+			r.names = append(r.names, task.Name)
+			r.mu.Unlock()
+			pool.Done()
+		}
+	}
+}
+
+// processed returns the names of the processed tasks, in the order they were processed.
+func (r *recorder) processed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+// waitFor polls cond until it returns true or timeout elapses, and reports whether it returned true.
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestIdleWorkerTimeout(t *testing.T) {
+	pool := NewWorkerPoolWithConfig(10, PoolConfig{IdleWorkerTimeout: 50 * time.Millisecond, MaxWorkers: 4})
+	var r recorder
+	pool.Start(4, r.worker(pool))
+	if !waitFor(2*time.Second, func() bool { return pool.ActiveWorkers() == 0 }) {
+		t.Fatalf("Idle workers did not exit, %d active", pool.ActiveWorkers())
+	}
+
+	for i := 0; i < 8; i++ {
+		pool.Submit(Task{EventType: fsnotify.Create, Name: fmt.Sprintf("%d.txt", i)})
+	}
+	pool.WG.Wait()
+	if got := len(r.processed()); got != 8 {
+		t.Errorf("Expected 8 tasks processed after scaling up, got %d", got)
+	}
+	if got := pool.ActiveWorkers(); got > 4 {
+		t.Errorf("Expected at most 4 workers, got %d", got)
+	}
+}
+
+func TestPoolStop(t *testing.T) {
+	pool := NewWorkerPool(10)
+	var r recorder
+	pool.Start(4, r.worker(pool))
+	for i := 0; i < 8; i++ {
+		pool.Submit(Task{EventType: fsnotify.Create, Name: fmt.Sprintf("%d.txt", i)})
+	}
+
+	// The queued tasks are processed before the workers exit.
+	pool.Stop()
+	if got := pool.ActiveWorkers(); got != 0 {
+		t.Errorf("Expected no workers after Stop, got %d", got)
+	}
+	if got := len(r.processed()); got != 8 {
+		t.Errorf("Expected 8 tasks processed before stopping, got %d", got)
+	}
+
+	// Workers are not restarted by Submit until Start is called again.
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "late.txt"})
+	if got := pool.ActiveWorkers(); got != 0 {
+		t.Errorf("Expected Submit not to start workers after Stop, got %d", got)
+	}
+	pool.Start(1, r.worker(pool))
+	pool.WG.Wait()
+	pool.Stop()
+	if got := len(r.processed()); got != 9 {
+		t.Errorf("Expected the late task after restarting, got %d tasks processed", got)
+	}
+}
+
+func TestPoolStopDiscardsQueuedTasks(t *testing.T) {
+	pool := NewWorkerPool(10)
+	var r recorder
+	release := make(chan struct{})
+	pool.Start(1, func() {
+		<-release
+		r.worker(pool)()
+	})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "first.txt"})
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	// Wait for the poison pill to be queued behind first.txt, then queue tasks behind it.
+	if !waitFor(2*time.Second, func() bool { return pool.Pending() == 2 }) {
+		t.Fatalf("Expected the poison pill to be queued, got %d pending", pool.Pending())
+	}
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "behind-1.txt"})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "behind-2.txt"})
+	close(release)
+	<-stopped
+
+	waited := make(chan struct{})
+	go func() {
+		pool.WG.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WG.Wait did not return after Stop discarded the queued tasks")
+	}
+	if got := r.processed(); len(got) != 1 || got[0] != "first.txt" {
+		t.Errorf("Expected only the task queued before Stop to be processed, got %v", got)
+	}
+	if got := pool.Pending(); got != 0 {
+		t.Errorf("Expected no queued tasks after Stop, got %d", got)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	pool := NewWorkerPool(10)
+	var r recorder
+	pool.Start(4, r.worker(pool))
+	var submitters sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("%d.txt", i)
+		pool.Submit(Task{EventType: fsnotify.Create, Name: name})
+
+		// Producers still submitting while the pool closes must not panic.
+		submitters.Add(1)
+		go func() {
+			defer submitters.Done()
+			pool.Submit(Task{EventType: fsnotify.Create, Name: name})
+		}()
+	}
+
+	pool.Close()
+	submitters.Wait()
+	if got := pool.ActiveWorkers(); got != 0 {
+		t.Errorf("Expected no workers after Close, got %d", got)
+	}
+	if got := len(r.processed()); got < 8 {
+		t.Errorf("Expected the 8 tasks queued before Close to be processed, got %d", got)
+	}
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "late.txt"})
+	pool.Close()
+	pool.WG.Wait()
+}
+
+func TestPoolCloseUrgentTasks(t *testing.T) {
+	pool := NewWorkerPoolWithConfig(100, PoolConfig{MaxWorkers: 1})
+	var r recorder
+	release := make(chan struct{})
+	pool.Start(1, func() {
+		<-release
+		r.worker(pool)()
+	})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "bulk"})
+	for i := 0; i < 2*urgentBurst; i++ {
+		pool.Submit(Task{EventType: fsnotify.Write, Name: fmt.Sprintf("urgent-%d", i), Priority: PriorityHigh})
+	}
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	if !waitFor(2*time.Second, pool.Closed) {
+		t.Fatal("Expected the pool to be closed")
+	}
+	close(release)
+	<-closed
+	if got := len(r.processed()); got != 2*urgentBurst+1 {
+		t.Errorf("Expected the %d tasks queued before Close to be processed, got %d", 2*urgentBurst+1, got)
+	}
+
+	// A worker receiving from the closed Tasks channel while high-priority tasks are still queued takes them first.
+	pool = NewWorkerPool(10)
+	pool.Submit(Task{EventType: fsnotify.Write, Name: "urgent", Priority: PriorityHigh})
+	close(pool.Tasks)
+	if task, ok := pool.received(Task{}, false); !ok || task.Name != "urgent" {
+		t.Errorf("received() = %+v, %t with Tasks closed, want the queued high-priority task", task, ok)
+	} else {
+		pool.Done()
+	}
+
+	// Without workers, the queued tasks are discarded so WG.Wait returns.
+	pool = NewWorkerPool(10)
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "bulk"})
+	pool.Submit(Task{EventType: fsnotify.Write, Name: "urgent", Priority: PriorityHigh})
+	pool.Close()
+	waited := make(chan struct{})
+	go func() {
+		pool.WG.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("WG.Wait did not return after Close discarded the queued tasks")
+	}
+	if got := pool.Pending(); got != 0 {
+		t.Errorf("Expected no queued tasks after Close, got %d", got)
+	}
+}
+
+func TestPoolPriority(t *testing.T) {
+	pool := NewWorkerPool(100)
+	for i := 0; i < 50; i++ {
+		pool.Submit(Task{EventType: fsnotify.Create, Name: fmt.Sprintf("bulk-%d", i)})
+	}
+	pool.Submit(Task{EventType: fsnotify.Write, Name: "config.yaml", Priority: PriorityHigh})
+
+	var r recorder
+	pool.Start(1, r.worker(pool))
+	pool.WG.Wait()
+	pool.Stop()
+	order := r.processed()
+	if len(order) != 51 || order[0] != "config.yaml" {
+		t.Fatalf("Expected the high-priority task first, got %d tasks starting with %q", len(order), order[0])
+	}
+	if order[1] != "bulk-0" || order[50] != "bulk-49" {
+		t.Errorf("Expected the normal tasks in submission order, got %q ... %q", order[1], order[50])
+	}
+}
+
+func TestPoolPriorityStarvation(t *testing.T) {
+	pool := NewWorkerPool(100)
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "bulk"})
+	for i := 0; i < 3*urgentBurst; i++ {
+		pool.Submit(Task{EventType: fsnotify.Write, Name: fmt.Sprintf("urgent-%d", i), Priority: PriorityHigh})
+	}
+
+	var r recorder
+	pool.Start(1, r.worker(pool))
+	pool.WG.Wait()
+	pool.Stop()
+	order := r.processed()
+	if len(order) != 3*urgentBurst+1 || order[urgentBurst] != "bulk" {
+		t.Errorf("Expected the normal task after %d high-priority tasks, got %v", urgentBurst, order)
+	}
+
+	// The high-priority tasks queued before Stop are processed even when the poison pill is taken ahead of them.
+	pool = NewWorkerPoolWithConfig(100, PoolConfig{MaxWorkers: 1})
+	r = recorder{}
+	release := make(chan struct{})
+	pool.Start(1, func() {
+		<-release
+		r.worker(pool)()
+	})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "bulk"})
+	for i := 0; i < 2*urgentBurst+4; i++ {
+		pool.Submit(Task{EventType: fsnotify.Write, Name: fmt.Sprintf("urgent-%d", i), Priority: PriorityHigh})
+	}
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	if !waitFor(2*time.Second, func() bool { return len(pool.Tasks) == 2 }) {
+		t.Fatalf("Expected the poison pill to be queued, got %d pending", pool.Pending())
+	}
+	close(release)
+	<-stopped
+	if got := len(r.processed()); got != 2*urgentBurst+5 {
+		t.Errorf("Expected the %d tasks queued before Stop to be processed, got %d", 2*urgentBurst+5, got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	pool := NewWorkerPool(10)
+	var r recorder
+	pool.Start(2, r.worker(pool))
+	defer pool.Stop()
+
+	pool.Pause()
+	pool.Pause()
+	if !pool.IsPaused() {
+		t.Fatal("Expected the pool to be paused")
+	}
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "file.txt"})
+	time.Sleep(100 * time.Millisecond)
+	if got := r.processed(); len(got) != 0 {
+		t.Fatalf("Expected no task processed while paused, got %v", got)
+	}
+
+	pool.Resume()
+	pool.Resume()
+	if pool.IsPaused() {
+		t.Fatal("Expected the pool to be resumed")
+	}
+	pool.WG.Wait()
+	if got := r.processed(); len(got) != 1 || got[0] != "file.txt" {
+		t.Errorf("Expected the queued task to be processed after Resume, got %v", got)
+	}
+}
+
+func TestPoolRetry(t *testing.T) {
+	// A single-slot queue: the retry of a failing task must not block the worker that resubmits it.
+	pool := NewWorkerPoolWithConfig(1, PoolConfig{MaxWorkers: 1})
+	var mu sync.Mutex
+	attempts := make(map[string][]int)
+	pool.Start(1, func() {
+		for {
+			task, ok := pool.Next()
+			if !ok {
+				return
+			}
+			mu.Lock()
+			attempts[task.Name] = append(attempts[task.Name], task.RetryCount)
+			mu.Unlock()
+			if task.RetryCount < 2 {
+				pool.Retry(task)
+			}
+			pool.Done()
+		}
+	})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "a.txt"})
+	pool.Submit(Task{EventType: fsnotify.Create, Name: "b.txt"})
+	pool.WG.Wait()
+	pool.Stop()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if got := attempts[name]; len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 2 {
+			t.Errorf("Expected %s to be processed with RetryCount 0, 1 and 2, got %v", name, got)
+		}
+	}
+
+	pool.Close()
+	pool.Retry(Task{EventType: fsnotify.Create, Name: "late.txt"})
+	pool.WG.Wait()
+	if got := pool.Pending(); got != 0 {
+		t.Errorf("Expected Retry to drop the task after Close, got %d queued", got)
+	}
+}